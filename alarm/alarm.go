@@ -0,0 +1,344 @@
+// Package alarm generates CloudWatch alarm templates from typed Go alarm definitions.
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	godeploycfn "github.com/moia-oss/go-deploy-cfn"
+	"github.com/moia-oss/go-deploy-cfn/template"
+)
+
+// Comparison operators of metric alarms.
+const (
+	GreaterThanOrEqualToThreshold = "GreaterThanOrEqualToThreshold"
+	GreaterThanThreshold          = "GreaterThanThreshold"
+	LessThanThreshold             = "LessThanThreshold"
+	LessThanOrEqualToThreshold    = "LessThanOrEqualToThreshold"
+)
+
+// Ways of treating missing data.
+const (
+	TreatMissingDataBreaching    = "breaching"
+	TreatMissingDataNotBreaching = "notBreaching"
+	TreatMissingDataIgnore       = "ignore"
+	TreatMissingDataMissing      = "missing"
+)
+
+var metricQueryIDRegexp = regexp.MustCompile(`^[a-z][a-zA-Z0-9_]*$`)
+
+// Dimension narrows down a metric. Value may be a string or an intrinsic function like template.Ref.
+type Dimension struct {
+	Name  string
+	Value interface{}
+}
+
+// Metric identifies a CloudWatch metric and how it is aggregated.
+type Metric struct {
+	Namespace  string
+	MetricName string
+	Dimensions []Dimension
+	// Period in seconds.
+	Period int
+	// Statistic is e.g. Average or Sum. For percentiles use ExtendedStatistic like p99 instead.
+	Statistic         string
+	ExtendedStatistic string
+	Unit              string
+}
+
+// MetricQuery is a single metric or math expression of a metric math alarm.
+type MetricQuery struct {
+	// ID is referenced by expressions, it must start with a lowercase letter.
+	ID string
+	// Either Expression or Metric must be set.
+	Expression string
+	Metric     *Metric
+	Label      string
+	// ReturnData marks the query the alarm is based on. If no query is marked, the last one is used.
+	ReturnData bool
+}
+
+// Alarm is a CloudWatch metric alarm, based on a single Metric or on metric math Queries.
+type Alarm struct {
+	// Name is the alarm name. The stack name and logical ID are derived from it.
+	Name        string
+	Description string
+
+	Metric  *Metric
+	Queries []MetricQuery
+
+	ComparisonOperator string
+	Threshold          float64
+	EvaluationPeriods  int
+	// DatapointsToAlarm defaults to EvaluationPeriods.
+	DatapointsToAlarm int
+	TreatMissingData  string
+
+	// Actions are ARNs as strings or intrinsic functions like template.Ref.
+	AlarmActions            []interface{}
+	OKActions               []interface{}
+	InsufficientDataActions []interface{}
+	// DisableActions turns off all actions of the alarm.
+	DisableActions bool
+}
+
+// StackName returns the name of the stack holding only this alarm.
+func (a *Alarm) StackName() string {
+	return godeploycfn.SafeStackName(a.Name)
+}
+
+// LogicalID returns the logical ID of the alarm resource.
+func (a *Alarm) LogicalID() string {
+	return godeploycfn.SafeLogicalName(a.Name)
+}
+
+// Validate checks the alarm definition for missing or conflicting settings.
+func (a *Alarm) Validate() error {
+	if a.Name == "" {
+		return errors.New("alarm has no name")
+	}
+
+	if (a.Metric == nil) == (len(a.Queries) == 0) {
+		return fmt.Errorf("alarm %s: exactly one of Metric and Queries must be set", a.Name)
+	}
+
+	if a.ComparisonOperator == "" {
+		return fmt.Errorf("alarm %s: ComparisonOperator is required", a.Name)
+	}
+
+	if a.EvaluationPeriods < 1 {
+		return fmt.Errorf("alarm %s: EvaluationPeriods must be at least 1", a.Name)
+	}
+
+	if a.DatapointsToAlarm > a.EvaluationPeriods {
+		return fmt.Errorf("alarm %s: DatapointsToAlarm must not exceed EvaluationPeriods", a.Name)
+	}
+
+	if a.Metric != nil {
+		return a.Metric.validate(a.Name)
+	}
+
+	returning := 0
+
+	for _, q := range a.Queries {
+		if !metricQueryIDRegexp.MatchString(q.ID) {
+			return fmt.Errorf("alarm %s: invalid metric query ID %q", a.Name, q.ID)
+		}
+
+		if (q.Expression == "") == (q.Metric == nil) {
+			return fmt.Errorf("alarm %s: metric query %s needs exactly one of Expression and Metric", a.Name, q.ID)
+		}
+
+		if q.Metric != nil {
+			if err := q.Metric.validate(a.Name); err != nil {
+				return err
+			}
+		}
+
+		if q.ReturnData {
+			returning++
+		}
+	}
+
+	if returning > 1 {
+		return fmt.Errorf("alarm %s: only one metric query may return data", a.Name)
+	}
+
+	return nil
+}
+
+func (m *Metric) validate(alarmName string) error {
+	if m.Namespace == "" || m.MetricName == "" {
+		return fmt.Errorf("alarm %s: metric needs Namespace and MetricName", alarmName)
+	}
+
+	if m.Period <= 0 {
+		return fmt.Errorf("alarm %s: metric %s needs a Period", alarmName, m.MetricName)
+	}
+
+	if (m.Statistic == "") == (m.ExtendedStatistic == "") {
+		return fmt.Errorf("alarm %s: metric %s needs exactly one of Statistic and ExtendedStatistic", alarmName, m.MetricName)
+	}
+
+	return nil
+}
+
+// Resource returns the AWS::CloudWatch::Alarm resource.
+func (a *Alarm) Resource() (*template.Resource, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	datapoints := a.DatapointsToAlarm
+	if datapoints == 0 {
+		datapoints = a.EvaluationPeriods
+	}
+
+	props := map[string]interface{}{
+		"AlarmName":          a.Name,
+		"ComparisonOperator": a.ComparisonOperator,
+		"Threshold":          a.Threshold,
+		"EvaluationPeriods":  a.EvaluationPeriods,
+		"DatapointsToAlarm":  datapoints,
+		"ActionsEnabled":     !a.DisableActions,
+	}
+
+	setIfNotEmpty(props, "AlarmDescription", a.Description)
+	setIfNotEmpty(props, "TreatMissingData", a.TreatMissingData)
+	setActions(props, a.AlarmActions, a.OKActions, a.InsufficientDataActions)
+
+	if a.Metric != nil {
+		props["Namespace"] = a.Metric.Namespace
+		props["MetricName"] = a.Metric.MetricName
+		props["Period"] = a.Metric.Period
+
+		setIfNotEmpty(props, "Statistic", a.Metric.Statistic)
+		setIfNotEmpty(props, "ExtendedStatistic", a.Metric.ExtendedStatistic)
+		setIfNotEmpty(props, "Unit", a.Metric.Unit)
+
+		if len(a.Metric.Dimensions) > 0 {
+			props["Dimensions"] = dimensions(a.Metric.Dimensions)
+		}
+	} else {
+		props["Metrics"] = a.metricQueries()
+	}
+
+	return &template.Resource{Type: "AWS::CloudWatch::Alarm", Properties: props}, nil
+}
+
+func (a *Alarm) metricQueries() []interface{} {
+	returnIndex := len(a.Queries) - 1
+
+	for i, q := range a.Queries {
+		if q.ReturnData {
+			returnIndex = i
+		}
+	}
+
+	queries := make([]interface{}, 0, len(a.Queries))
+
+	for i, q := range a.Queries {
+		query := map[string]interface{}{
+			"Id":         q.ID,
+			"ReturnData": i == returnIndex,
+		}
+
+		setIfNotEmpty(query, "Label", q.Label)
+		setIfNotEmpty(query, "Expression", q.Expression)
+
+		if q.Metric != nil {
+			metric := map[string]interface{}{
+				"Namespace":  q.Metric.Namespace,
+				"MetricName": q.Metric.MetricName,
+			}
+
+			if len(q.Metric.Dimensions) > 0 {
+				metric["Dimensions"] = dimensions(q.Metric.Dimensions)
+			}
+
+			stat := q.Metric.Statistic
+			if stat == "" {
+				stat = q.Metric.ExtendedStatistic
+			}
+
+			metricStat := map[string]interface{}{
+				"Metric": metric,
+				"Period": q.Metric.Period,
+				"Stat":   stat,
+			}
+			setIfNotEmpty(metricStat, "Unit", q.Metric.Unit)
+
+			query["MetricStat"] = metricStat
+		}
+
+		queries = append(queries, query)
+	}
+
+	return queries
+}
+
+func dimensions(dims []Dimension) []interface{} {
+	out := make([]interface{}, 0, len(dims))
+	for _, d := range dims {
+		out = append(out, map[string]interface{}{"Name": d.Name, "Value": d.Value})
+	}
+
+	return out
+}
+
+func setIfNotEmpty(m map[string]interface{}, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
+
+func setActions(props map[string]interface{}, alarm, ok, insufficientData []interface{}) {
+	if len(alarm) > 0 {
+		props["AlarmActions"] = alarm
+	}
+
+	if len(ok) > 0 {
+		props["OKActions"] = ok
+	}
+
+	if len(insufficientData) > 0 {
+		props["InsufficientDataActions"] = insufficientData
+	}
+}
+
+// Template returns a deployable template holding only this alarm, see StackName.
+func (a *Alarm) Template() (*template.Template, error) {
+	return NewTemplate(fmt.Sprintf("CloudWatch alarm %s", a.Name), []*Alarm{a}, nil)
+}
+
+// NewTemplate returns a template holding all given alarms and composite alarms. Composite alarms
+// depend on the alarms of the template they refer to.
+func NewTemplate(description string, alarms []*Alarm, composites []*CompositeAlarm) (*template.Template, error) {
+	t := template.New(description)
+	logicalIDs := map[string]string{}
+
+	for _, a := range alarms {
+		r, err := a.Resource()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = t.AddResource(a.LogicalID(), r); err != nil {
+			return nil, fmt.Errorf("alarm %s: %w", a.Name, err)
+		}
+
+		logicalIDs[a.Name] = a.LogicalID()
+	}
+
+	for _, c := range composites {
+		r, err := c.Resource()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range c.Rule.alarmNames() {
+			if id, ok := logicalIDs[name]; ok {
+				r.DependsOn = appendUnique(r.DependsOn, id)
+			}
+		}
+
+		if _, err = t.AddResource(c.LogicalID(), r); err != nil {
+			return nil, fmt.Errorf("composite alarm %s: %w", c.Name, err)
+		}
+
+		logicalIDs[c.Name] = c.LogicalID()
+	}
+
+	return t, nil
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+
+	return append(s, v)
+}
@@ -0,0 +1,182 @@
+package alarm
+
+import (
+	"strings"
+	"testing"
+
+	godeploycfn "github.com/moia-oss/go-deploy-cfn"
+	"github.com/moia-oss/go-deploy-cfn/template"
+)
+
+func lambdaErrors() *Alarm {
+	return &Alarm{
+		Name: "AWS/Lambda/Global/Error",
+		Metric: &Metric{
+			Namespace:  "AWS/Lambda",
+			MetricName: "Errors",
+			Dimensions: []Dimension{{Name: "FunctionName", Value: "my-function"}},
+			Period:     60,
+			Statistic:  "Sum",
+		},
+		ComparisonOperator: GreaterThanThreshold,
+		Threshold:          0,
+		EvaluationPeriods:  3,
+		AlarmActions:       []interface{}{"arn:aws:sns:eu-central-1:123456789012:alerts"},
+	}
+}
+
+func errorRate() *Alarm {
+	invocations := &Metric{Namespace: "AWS/Lambda", MetricName: "Invocations", Period: 60, Statistic: "Sum"}
+	errs := &Metric{Namespace: "AWS/Lambda", MetricName: "Errors", Period: 60, Statistic: "Sum"}
+
+	return &Alarm{
+		Name: "AWS/Lambda/Global/ErrorRate",
+		Queries: []MetricQuery{
+			{ID: "invocations", Metric: invocations},
+			{ID: "errors", Metric: errs},
+			{ID: "rate", Expression: "100 * errors / invocations", Label: "Error rate"},
+		},
+		ComparisonOperator: GreaterThanOrEqualToThreshold,
+		Threshold:          5,
+		EvaluationPeriods:  5,
+		DatapointsToAlarm:  3,
+		TreatMissingData:   TreatMissingDataNotBreaching,
+	}
+}
+
+func TestAlarm_Template(t *testing.T) {
+	a := lambdaErrors()
+
+	if got := a.StackName(); !strings.HasPrefix(got, "AWS-Lambda-Global-Error-") || godeploycfn.ValidateStackName(got) != nil {
+		t.Errorf("StackName() = %s, want a valid stack name starting with AWS-Lambda-Global-Error-", got)
+	}
+
+	tmpl, err := a.Template()
+	if err != nil {
+		t.Fatalf("Template() unexpected error = %v", err)
+	}
+
+	r := tmpl.Resources[a.LogicalID()]
+	if r == nil || r.Type != "AWS::CloudWatch::Alarm" {
+		t.Fatalf("Template() resources = %v, want %s alarm", tmpl.LogicalIDs(), a.LogicalID())
+	}
+
+	if r.Properties["DatapointsToAlarm"] != 3 || r.Properties["Statistic"] != "Sum" {
+		t.Errorf("unexpected properties %v", r.Properties)
+	}
+
+	findings, err := tmpl.Lint()
+	if err != nil || len(findings) != 0 {
+		t.Errorf("Lint() = %v, %v, want no findings", findings, err)
+	}
+}
+
+func TestAlarm_metricMath(t *testing.T) {
+	r, err := errorRate().Resource()
+	if err != nil {
+		t.Fatalf("Resource() unexpected error = %v", err)
+	}
+
+	metrics, _ := r.Properties["Metrics"].([]interface{})
+	if len(metrics) != 3 {
+		t.Fatalf("Metrics = %v, want 3 queries", r.Properties["Metrics"])
+	}
+
+	for i, want := range []bool{false, false, true} {
+		if got := metrics[i].(map[string]interface{})["ReturnData"]; got != want {
+			t.Errorf("query %d ReturnData = %v, want %v", i, got, want)
+		}
+	}
+
+	if _, ok := r.Properties["MetricName"]; ok {
+		t.Error("metric math alarms must not have a MetricName")
+	}
+}
+
+func TestAlarm_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(a *Alarm)
+		wantErr string
+	}{
+		{name: "Test valid alarm", modify: func(*Alarm) {}},
+		{name: "Test metric and queries", modify: func(a *Alarm) { a.Queries = errorRate().Queries }, wantErr: "exactly one of Metric and Queries"},
+		{name: "Test missing period", modify: func(a *Alarm) { a.Metric.Period = 0 }, wantErr: "needs a Period"},
+		{name: "Test both statistics", modify: func(a *Alarm) { a.Metric.ExtendedStatistic = "p99" }, wantErr: "exactly one of Statistic"},
+		{name: "Test datapoints", modify: func(a *Alarm) { a.DatapointsToAlarm = 4 }, wantErr: "DatapointsToAlarm"},
+		{name: "Test invalid query ID", modify: func(a *Alarm) {
+			a.Metric = nil
+			a.Queries = []MetricQuery{{ID: "Rate", Expression: "1"}}
+		}, wantErr: "invalid metric query ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := lambdaErrors()
+			tt.modify(a)
+
+			err := a.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() unexpected error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlarm_names(t *testing.T) {
+	names := []string{"my alarm", "my_alarm", "my:alarm", "myalarm"}
+	stackNames, logicalIDs := map[string]string{}, map[string]string{}
+
+	for _, name := range names {
+		a := &Alarm{Name: name}
+
+		if err := godeploycfn.ValidateStackName(a.StackName()); err != nil {
+			t.Errorf("StackName(%q) = %v", name, err)
+		}
+
+		if other, ok := stackNames[a.StackName()]; ok {
+			t.Errorf("StackName(%q) = StackName(%q) = %s", name, other, a.StackName())
+		}
+
+		if other, ok := logicalIDs[a.LogicalID()]; ok {
+			t.Errorf("LogicalID(%q) = LogicalID(%q) = %s", name, other, a.LogicalID())
+		}
+
+		stackNames[a.StackName()], logicalIDs[a.LogicalID()] = name, name
+	}
+}
+
+func TestNewTemplate_composite(t *testing.T) {
+	composite := &CompositeAlarm{
+		Name: "AWS/Lambda/Global/Critical",
+		Rule: AllOf(InAlarm("AWS/Lambda/Global/Error"), Not(InOK("AWS/Lambda/Global/ErrorRate")), InAlarm("external")),
+		AlarmActions: []interface{}{
+			template.Sub{String: "arn:aws:sns:${AWS::Region}:${AWS::AccountId}:alerts"},
+		},
+	}
+
+	tmpl, err := NewTemplate("lambda alarms", []*Alarm{lambdaErrors(), errorRate()}, []*CompositeAlarm{composite})
+	if err != nil {
+		t.Fatalf("NewTemplate() unexpected error = %v", err)
+	}
+
+	r := tmpl.Resources[composite.LogicalID()]
+
+	wantRule := `(ALARM("AWS/Lambda/Global/Error")) AND (NOT (OK("AWS/Lambda/Global/ErrorRate"))) AND (ALARM("external"))`
+	if got := r.Properties["AlarmRule"]; got != wantRule {
+		t.Errorf("AlarmRule = %v, want %v", got, wantRule)
+	}
+
+	if strings.Join(r.DependsOn, ",") != lambdaErrors().LogicalID()+","+errorRate().LogicalID() {
+		t.Errorf("DependsOn = %v", r.DependsOn)
+	}
+
+	findings, err := tmpl.Lint()
+	if err != nil || len(findings) != 0 {
+		t.Errorf("Lint() = %v, %v, want no findings", findings, err)
+	}
+}
@@ -0,0 +1,128 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	godeploycfn "github.com/moia-oss/go-deploy-cfn"
+	"github.com/moia-oss/go-deploy-cfn/template"
+)
+
+// Rule is the alarm rule of a composite alarm.
+type Rule interface {
+	String() string
+	alarmNames() []string
+}
+
+type stateRule struct {
+	state string
+	alarm string
+}
+
+func (r stateRule) String() string { return fmt.Sprintf("%s(%s)", r.state, strconv.Quote(r.alarm)) }
+
+func (r stateRule) alarmNames() []string { return []string{r.alarm} }
+
+// InAlarm holds if the named alarm is in ALARM state.
+func InAlarm(alarmName string) Rule { return stateRule{state: "ALARM", alarm: alarmName} }
+
+// InOK holds if the named alarm is in OK state.
+func InOK(alarmName string) Rule { return stateRule{state: "OK", alarm: alarmName} }
+
+// InInsufficientData holds if the named alarm is in INSUFFICIENT_DATA state.
+func InInsufficientData(alarmName string) Rule {
+	return stateRule{state: "INSUFFICIENT_DATA", alarm: alarmName}
+}
+
+type combinedRule struct {
+	operator string
+	rules    []Rule
+}
+
+func (r combinedRule) String() string {
+	parts := make([]string, 0, len(r.rules))
+	for _, rule := range r.rules {
+		parts = append(parts, "("+rule.String()+")")
+	}
+
+	return strings.Join(parts, " "+r.operator+" ")
+}
+
+func (r combinedRule) alarmNames() []string {
+	var names []string
+	for _, rule := range r.rules {
+		names = append(names, rule.alarmNames()...)
+	}
+
+	return names
+}
+
+// AllOf holds if all of the rules hold.
+func AllOf(rules ...Rule) Rule { return combinedRule{operator: "AND", rules: rules} }
+
+// AnyOf holds if any of the rules holds.
+func AnyOf(rules ...Rule) Rule { return combinedRule{operator: "OR", rules: rules} }
+
+type notRule struct {
+	rule Rule
+}
+
+func (r notRule) String() string { return "NOT (" + r.rule.String() + ")" }
+
+func (r notRule) alarmNames() []string { return r.rule.alarmNames() }
+
+// Not holds if rule doesn't.
+func Not(rule Rule) Rule { return notRule{rule: rule} }
+
+// CompositeAlarm combines the states of other alarms.
+type CompositeAlarm struct {
+	Name        string
+	Description string
+	Rule        Rule
+
+	// Actions are ARNs as strings or intrinsic functions like template.Ref.
+	AlarmActions            []interface{}
+	OKActions               []interface{}
+	InsufficientDataActions []interface{}
+	// DisableActions turns off all actions of the alarm.
+	DisableActions bool
+}
+
+// StackName returns the name of the stack holding only this composite alarm.
+func (c *CompositeAlarm) StackName() string {
+	return godeploycfn.SafeStackName(c.Name)
+}
+
+// LogicalID returns the logical ID of the composite alarm resource.
+func (c *CompositeAlarm) LogicalID() string {
+	return godeploycfn.SafeLogicalName(c.Name)
+}
+
+// Resource returns the AWS::CloudWatch::CompositeAlarm resource.
+func (c *CompositeAlarm) Resource() (*template.Resource, error) {
+	if c.Name == "" {
+		return nil, errors.New("composite alarm has no name")
+	}
+
+	if c.Rule == nil {
+		return nil, fmt.Errorf("composite alarm %s: Rule is required", c.Name)
+	}
+
+	props := map[string]interface{}{
+		"AlarmName":      c.Name,
+		"AlarmRule":      c.Rule.String(),
+		"ActionsEnabled": !c.DisableActions,
+	}
+
+	setIfNotEmpty(props, "AlarmDescription", c.Description)
+	setActions(props, c.AlarmActions, c.OKActions, c.InsufficientDataActions)
+
+	return &template.Resource{Type: "AWS::CloudWatch::CompositeAlarm", Properties: props}, nil
+}
+
+// Template returns a deployable template holding only this composite alarm, see StackName.
+func (c *CompositeAlarm) Template() (*template.Template, error) {
+	return NewTemplate(fmt.Sprintf("CloudWatch composite alarm %s", c.Name), nil, []*CompositeAlarm{c})
+}
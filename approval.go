@@ -0,0 +1,259 @@
+package godeploycfn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// ApprovalDecision is the outcome of an Approver looking at a ChangeSet.
+type ApprovalDecision int
+
+const (
+	// ApprovalApproved executes the ChangeSet.
+	ApprovalApproved ApprovalDecision = iota
+	// ApprovalRejected deletes the ChangeSet without executing it.
+	ApprovalRejected
+	// ApprovalDeferred leaves the ChangeSet in place so it can be executed later.
+	ApprovalDeferred
+)
+
+var (
+	// ErrChangeSetRejected is returned by CloudFormationDeploy when the Approver rejected the ChangeSet.
+	ErrChangeSetRejected = errors.New("change set was rejected")
+	// ErrChangeSetDeferred matches the *ChangeSetDeferredError returned when the Approver deferred the ChangeSet.
+	ErrChangeSetDeferred = errors.New("change set was deferred")
+)
+
+// ChangeSetDeferredError is returned by CloudFormationDeploy when the Approver deferred the ChangeSet. The ChangeSet
+// is kept, pass the Plan to Apply to execute it later.
+type ChangeSetDeferredError struct {
+	StackName     string
+	ChangeSetName string
+	// ChangeSetID is the ARN of the ChangeSet.
+	ChangeSetID string
+	Plan        *DeploymentPlan
+}
+
+// Error returns the deferred ChangeSet.
+func (e *ChangeSetDeferredError) Error() string {
+	return fmt.Sprintf("change set %s of stack %s was deferred", e.ChangeSetName, e.StackName)
+}
+
+// Is reports whether target is ErrChangeSetDeferred.
+func (e *ChangeSetDeferredError) Is(target error) bool {
+	return target == ErrChangeSetDeferred //nolint:errorlint
+}
+
+// String returns a human-readable representation of the decision.
+func (d ApprovalDecision) String() string {
+	switch d {
+	case ApprovalApproved:
+		return "approved"
+	case ApprovalRejected:
+		return "rejected"
+	case ApprovalDeferred:
+		return "deferred"
+	}
+
+	return fmt.Sprintf("ApprovalDecision(%d)", int(d))
+}
+
+// Approver is invoked with the described ChangeSet before it gets executed.
+type Approver interface {
+	Approve(changeSet *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error)
+}
+
+// ApproverFunc allows using an ordinary function as Approver.
+type ApproverFunc func(changeSet *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error)
+
+// Approve calls f(changeSet).
+func (f ApproverFunc) Approve(changeSet *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+	return f(changeSet)
+}
+
+// TerminalApprover prints the changes of a ChangeSet and asks for confirmation.
+// Anything but an explicit "y" or "yes" rejects the ChangeSet.
+type TerminalApprover struct {
+	// In defaults to os.Stdin.
+	In io.Reader
+	// Out defaults to os.Stdout.
+	Out io.Writer
+	// Color enables colored output of the changes.
+	Color bool
+}
+
+// Approve renders the changes and prompts y/N.
+func (t *TerminalApprover) Approve(changeSet *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+	in, out := t.In, t.Out
+	if in == nil {
+		in = os.Stdin
+	}
+
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if err := (TableRenderer{Color: t.Color}).Render(out, changeSet); err != nil {
+		return ApprovalRejected, err
+	}
+
+	if _, err := fmt.Fprint(out, "Execute this ChangeSet? [y/N] "); err != nil {
+		return ApprovalRejected, fmt.Errorf("error writing prompt: %w", err)
+	}
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ApprovalRejected, fmt.Errorf("error reading answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return ApprovalApproved, nil
+	}
+
+	return ApprovalRejected, nil
+}
+
+// NonDestructiveApprover approves ChangeSets which neither remove nor (possibly) replace a resource.
+// Other ChangeSets are handed to Fallback, or deferred if there is none.
+type NonDestructiveApprover struct {
+	Fallback Approver
+}
+
+// Approve approves the ChangeSet if it is non-destructive.
+func (n *NonDestructiveApprover) Approve(changeSet *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+	if !changeSetIsDestructive(changeSet) {
+		return ApprovalApproved, nil
+	}
+
+	if n.Fallback == nil {
+		return ApprovalDeferred, nil
+	}
+
+	return n.Fallback.Approve(changeSet)
+}
+
+// changeSetIsDestructive reports whether a resource gets removed or might get replaced.
+func changeSetIsDestructive(o *cloudformation.DescribeChangeSetOutput) bool {
+	for _, change := range o.Changes {
+		rc := change.ResourceChange
+		if rc == nil {
+			continue
+		}
+
+		if aws.StringValue(rc.Action) == cloudformation.ChangeActionRemove {
+			return true
+		}
+
+		switch aws.StringValue(rc.Replacement) {
+		case cloudformation.ReplacementTrue, cloudformation.ReplacementConditional:
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeChangeSet describes the ChangeSet and collects the changes of all pages.
+func (c *Cloudformation) describeChangeSet(dcsi *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	input := *dcsi
+
+	dcso, err := c.CFClient.DescribeChangeSet(&input)
+	if err != nil {
+		return nil, fmt.Errorf("error describing the ChangeSet: %w", err)
+	}
+
+	for token := dcso.NextToken; token != nil; {
+		input.NextToken = token
+
+		page, err := c.CFClient.DescribeChangeSet(&input)
+		if err != nil {
+			return nil, fmt.Errorf("error describing the ChangeSet: %w", err)
+		}
+
+		dcso.Changes = append(dcso.Changes, page.Changes...)
+		token = page.NextToken
+	}
+
+	dcso.NextToken = nil
+
+	if c.IncludeNestedStacks {
+		if err = c.addNestedChanges(dcso); err != nil {
+			return nil, err
+		}
+	}
+
+	return dcso, nil
+}
+
+// approveChangeSet checks the ChangeSet against the Guardrails and asks the configured Approver whether it
+// may be executed. A ChangeSet which violates the Guardrails or is rejected is deleted again, a deferred
+// ChangeSet is returned as plan of type changeSetType in a *ChangeSetDeferredError.
+func (c *Cloudformation) approveChangeSet(dcsi *cloudformation.DescribeChangeSetInput, changeSetType string) error {
+	if c.Approver == nil && c.Guardrails == nil {
+		return nil
+	}
+
+	dcso, err := c.describeChangeSet(dcsi)
+	if err != nil {
+		return err
+	}
+
+	if err = c.enforceGuardrails(dcso); err != nil {
+		if _, err2 := c.CFClient.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			ChangeSetName: dcsi.ChangeSetName,
+			StackName:     dcsi.StackName,
+		}); err2 != nil {
+			return fmt.Errorf("couldn't delete change set violating guardrails: %w", err2)
+		}
+
+		return err
+	}
+
+	if c.Approver == nil {
+		return nil
+	}
+
+	decision, err := c.Approver.Approve(dcso)
+	if err != nil {
+		return fmt.Errorf("error while approving the ChangeSet: %w", err)
+	}
+
+	c.logger().Infof("ChangeSet '%s' has been %s.", aws.StringValue(dcso.ChangeSetName), decision)
+
+	switch decision {
+	case ApprovalApproved:
+		return nil
+	case ApprovalDeferred:
+		var plan *DeploymentPlan
+		if plan, err = c.newDeploymentPlan(dcso, changeSetType); err != nil {
+			return err
+		}
+
+		return &ChangeSetDeferredError{
+			StackName:     c.StackName,
+			ChangeSetName: aws.StringValue(dcso.ChangeSetName),
+			ChangeSetID:   aws.StringValue(dcso.ChangeSetId),
+			Plan:          plan,
+		}
+	case ApprovalRejected:
+		_, err = c.CFClient.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			ChangeSetName: dcsi.ChangeSetName,
+			StackName:     dcsi.StackName,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't delete rejected change set: %w", err)
+		}
+
+		return ErrChangeSetRejected
+	}
+
+	return fmt.Errorf("unknown approval decision: %s", decision)
+}
@@ -0,0 +1,253 @@
+package godeploycfn
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockChangeSetClient struct {
+	cloudformationiface.CloudFormationAPI
+	pages   []*cloudformation.DescribeChangeSetOutput
+	deleted *[]string
+}
+
+// DescribeChangeSet returns the pages in order, using the page index as NextToken.
+func (m mockChangeSetClient) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	page := 0
+	if input.NextToken != nil {
+		page = len(*input.NextToken)
+	}
+
+	out := *m.pages[page]
+	if page+1 < len(m.pages) {
+		out.NextToken = aws.String(strings.Repeat("x", page+1))
+	}
+
+	return &out, nil
+}
+
+func (m mockChangeSetClient) DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	*m.deleted = append(*m.deleted, *input.ChangeSetName)
+
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (m mockChangeSetClient) DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{
+		StackName:   aws.String("stack"),
+		StackStatus: aws.String(cloudformation.StackStatusUpdateComplete),
+	}}}, nil
+}
+
+func resourceChange(action, logicalID, replacement string) *cloudformation.Change {
+	rc := &cloudformation.ResourceChange{
+		Action:            aws.String(action),
+		LogicalResourceId: aws.String(logicalID),
+		ResourceType:      aws.String("AWS::SNS::Topic"),
+	}
+	if replacement != "" {
+		rc.Replacement = aws.String(replacement)
+	}
+
+	return &cloudformation.Change{
+		ResourceChange: rc,
+		Type:           aws.String(cloudformation.ChangeTypeResource),
+	}
+}
+
+func TestCloudformation_approveChangeSet(t *testing.T) {
+	pages := []*cloudformation.DescribeChangeSetOutput{
+		{
+			ChangeSetName: aws.String("cs"),
+			Changes:       []*cloudformation.Change{resourceChange(cloudformation.ChangeActionAdd, "Topic", "")},
+		},
+		{
+			Changes: []*cloudformation.Change{resourceChange(cloudformation.ChangeActionModify, "Alarm", cloudformation.ReplacementTrue)},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		decision    ApprovalDecision
+		wantErr     error
+		wantDeleted int
+	}{
+		{
+			name:     "Test approved change set",
+			decision: ApprovalApproved,
+		},
+		{
+			name:        "Test rejected change set gets deleted",
+			decision:    ApprovalRejected,
+			wantErr:     ErrChangeSetRejected,
+			wantDeleted: 1,
+		},
+		{
+			name:     "Test deferred change set is kept",
+			decision: ApprovalDeferred,
+			wantErr:  ErrChangeSetDeferred,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+
+			var seen int
+
+			c := &Cloudformation{
+				CFClient:  mockChangeSetClient{pages: pages, deleted: &deleted},
+				StackName: "stack",
+				Approver: ApproverFunc(func(o *cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+					seen = len(o.Changes)
+
+					return tt.decision, nil
+				}),
+			}
+
+			err := c.approveChangeSet(&cloudformation.DescribeChangeSetInput{
+				ChangeSetName: aws.String("cs"),
+				StackName:     aws.String("stack"),
+			}, cloudformation.ChangeSetTypeUpdate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("approveChangeSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if seen != 2 {
+				t.Errorf("approver saw %d changes, want 2", seen)
+			}
+
+			if len(deleted) != tt.wantDeleted {
+				t.Errorf("deleted %d change sets, want %d", len(deleted), tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestCloudformation_CloudFormationDeploy_deferred(t *testing.T) {
+	client := newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", ""))
+	c := &Cloudformation{
+		CFClient:  client,
+		StackName: "test",
+		Approver: ApproverFunc(func(*cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+			return ApprovalDeferred, nil
+		}),
+	}
+
+	var deferred *ChangeSetDeferredError
+
+	err := c.CloudFormationDeploy(hashTemplate, false)
+	if !errors.As(err, &deferred) || !errors.Is(err, ErrChangeSetDeferred) {
+		t.Fatalf("CloudFormationDeploy() error = %v, want *ChangeSetDeferredError", err)
+	}
+
+	plan := deferred.Plan
+	if deferred.ChangeSetName != "cs" || deferred.ChangeSetID == "" || plan.ChangeSetID != deferred.ChangeSetID ||
+		plan.ChangeSetType != cloudformation.ChangeSetTypeUpdate || len(plan.Changes) != 1 || *client.executed {
+		t.Fatalf("CloudFormationDeploy() deferred %+v with plan %+v", deferred, plan)
+	}
+
+	c.Approver = nil
+	if err = c.Apply(plan); err != nil {
+		t.Fatalf("Apply() unexpected error = %v", err)
+	}
+
+	if !*client.executed {
+		t.Error("Apply() didn't execute the deferred ChangeSet")
+	}
+}
+
+func TestNonDestructiveApprover_Approve(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  []*cloudformation.Change
+		fallback Approver
+		want     ApprovalDecision
+	}{
+		{
+			name: "Test adding and modifying is approved",
+			changes: []*cloudformation.Change{
+				resourceChange(cloudformation.ChangeActionAdd, "Topic", ""),
+				resourceChange(cloudformation.ChangeActionModify, "Alarm", cloudformation.ReplacementFalse),
+			},
+			want: ApprovalApproved,
+		},
+		{
+			name:    "Test removal is deferred",
+			changes: []*cloudformation.Change{resourceChange(cloudformation.ChangeActionRemove, "Topic", "")},
+			want:    ApprovalDeferred,
+		},
+		{
+			name:    "Test conditional replacement is deferred",
+			changes: []*cloudformation.Change{resourceChange(cloudformation.ChangeActionModify, "Topic", cloudformation.ReplacementConditional)},
+			want:    ApprovalDeferred,
+		},
+		{
+			name:    "Test replacement is handed to fallback",
+			changes: []*cloudformation.Change{resourceChange(cloudformation.ChangeActionModify, "Topic", cloudformation.ReplacementTrue)},
+			fallback: ApproverFunc(func(*cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+				return ApprovalRejected, nil
+			}),
+			want: ApprovalRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NonDestructiveApprover{Fallback: tt.fallback}
+
+			got, err := n.Approve(&cloudformation.DescribeChangeSetOutput{Changes: tt.changes})
+			if err != nil {
+				t.Fatalf("Approve() unexpected error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Approve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminalApprover_Approve(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ApprovalDecision
+	}{
+		{name: "Test yes approves", input: "yes\n", want: ApprovalApproved},
+		{name: "Test Y approves", input: "Y\n", want: ApprovalApproved},
+		{name: "Test empty answer rejects", input: "\n", want: ApprovalRejected},
+		{name: "Test EOF rejects", input: "", want: ApprovalRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			a := &TerminalApprover{In: strings.NewReader(tt.input), Out: &out}
+
+			got, err := a.Approve(&cloudformation.DescribeChangeSetOutput{
+				ChangeSetName: aws.String("cs"),
+				StackName:     aws.String("stack"),
+				Changes:       []*cloudformation.Change{resourceChange(cloudformation.ChangeActionAdd, "MyTopic", "")},
+			})
+			if err != nil {
+				t.Fatalf("Approve() unexpected error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Approve() = %v, want %v", got, tt.want)
+			}
+
+			if !strings.Contains(out.String(), "MyTopic") {
+				t.Errorf("expected rendered changes to contain the logical id, got %q", out.String())
+			}
+		})
+	}
+}
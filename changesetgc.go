@@ -0,0 +1,131 @@
+package godeploycfn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/uuid"
+)
+
+// isOwnChangeSet reports whether the ChangeSet was named by CloudFormationDeploy: `<stack>-<uuid>`.
+func (c *Cloudformation) isOwnChangeSet(name string) bool {
+	prefix := trimStackName(c.StackName, 91) + "-"
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+
+	id := strings.TrimPrefix(name, prefix)
+	_, err := uuid.Parse(id)
+
+	return err == nil && len(id) == 36
+}
+
+// DeleteStaleChangeSets deletes the ChangeSets created by this library which failed, or which are older than
+// maxAge if it's greater than zero. ChangeSets which are still being created or executed are kept. ChangeSets
+// created while an Approver was set, which may have been deferred, expire after DeferredChangeSetAge instead if
+// it's set, and ChangeSets created by Plan are kept unless they are older than MaxPlanAge, so Apply would refuse
+// them anyway.
+// It returns the names of the deleted ChangeSets.
+func (c *Cloudformation) DeleteStaleChangeSets(maxAge time.Duration) ([]string, error) {
+	stale, err := c.staleChangeSets(maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+
+	for _, summary := range stale {
+		name := aws.StringValue(summary.ChangeSetName)
+
+		_, err = c.CFClient.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			ChangeSetName: summary.ChangeSetId,
+			StackName:     aws.String(c.StackName),
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting change set %s: %w", name, err)
+		}
+
+		c.logger().Infof("Deleted stale ChangeSet '%s' with status %s created at %s.", name,
+			aws.StringValue(summary.Status), aws.TimeValue(summary.CreationTime).Format(time.RFC3339))
+
+		deleted = append(deleted, name)
+	}
+
+	return deleted, nil
+}
+
+// staleChangeSets lists all ChangeSets of the stack and returns the stale ones. The listing is completed before
+// anything is deleted, as deleting would change the pages.
+func (c *Cloudformation) staleChangeSets(maxAge time.Duration) ([]*cloudformation.ChangeSetSummary, error) {
+	var stale []*cloudformation.ChangeSetSummary
+
+	input := &cloudformation.ListChangeSetsInput{StackName: aws.String(c.StackName)}
+
+	for {
+		out, err := c.CFClient.ListChangeSets(input)
+		if err != nil {
+			if strings.Contains(err.Error(), "does not exist") {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("error listing change sets: %w", err)
+		}
+
+		for _, summary := range out.Summaries {
+			if c.isStaleChangeSet(summary, maxAge) {
+				stale = append(stale, summary)
+			}
+		}
+
+		if out.NextToken == nil {
+			return stale, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
+
+func (c *Cloudformation) isStaleChangeSet(summary *cloudformation.ChangeSetSummary, maxAge time.Duration) bool {
+	if !c.isOwnChangeSet(aws.StringValue(summary.ChangeSetName)) {
+		return false
+	}
+
+	switch aws.StringValue(summary.Status) {
+	case cloudformation.ChangeSetStatusCreatePending, cloudformation.ChangeSetStatusCreateInProgress:
+		return false
+	case cloudformation.ChangeSetStatusFailed:
+		return true
+	}
+
+	if aws.StringValue(summary.ExecutionStatus) == cloudformation.ExecutionStatusExecuteInProgress {
+		return false
+	}
+
+	age := time.Since(aws.TimeValue(summary.CreationTime))
+
+	switch kind, _ := parseChangeSetDescription(aws.StringValue(summary.Description)); kind {
+	case changeSetKindApproval:
+		if c.DeferredChangeSetAge > 0 {
+			maxAge = c.DeferredChangeSetAge
+		}
+	case changeSetKindPlan:
+		return c.MaxPlanAge > 0 && age > c.MaxPlanAge
+	}
+
+	return maxAge > 0 && age > maxAge
+}
+
+// deleteStaleChangeSets runs DeleteStaleChangeSets before a deployment if StaleChangeSetAge is set.
+// Errors are only logged, as they don't affect the deployment.
+func (c *Cloudformation) deleteStaleChangeSets() {
+	if c.StaleChangeSetAge <= 0 {
+		return
+	}
+
+	if _, err := c.DeleteStaleChangeSets(c.StaleChangeSetAge); err != nil {
+		c.logger().Warnf("Couldn't delete stale ChangeSets: %v", err)
+	}
+}
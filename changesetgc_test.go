@@ -0,0 +1,152 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+type mockChangeSetListClient struct {
+	mockPlanClient
+	pages   [][]*cloudformation.ChangeSetSummary
+	deleted *[]string
+}
+
+func (m mockChangeSetListClient) ListChangeSets(input *cloudformation.ListChangeSetsInput) (*cloudformation.ListChangeSetsOutput, error) {
+	page := 0
+	if input.NextToken != nil {
+		page = 1
+	}
+
+	if page > 0 && len(*m.deleted) > 0 {
+		return nil, errors.New("NextToken is invalid after deleting change sets")
+	}
+
+	out := &cloudformation.ListChangeSetsOutput{Summaries: m.pages[page]}
+	if page+1 < len(m.pages) {
+		out.NextToken = aws.String("page-2")
+	}
+
+	return out, nil
+}
+
+func (m mockChangeSetListClient) DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	*m.deleted = append(*m.deleted, aws.StringValue(input.ChangeSetName))
+
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func changeSetSummary(name, status, executionStatus string, age time.Duration) *cloudformation.ChangeSetSummary {
+	return &cloudformation.ChangeSetSummary{
+		ChangeSetId:     aws.String("arn:" + name),
+		ChangeSetName:   aws.String(name),
+		Status:          aws.String(status),
+		ExecutionStatus: aws.String(executionStatus),
+		CreationTime:    aws.Time(time.Now().Add(-age)),
+	}
+}
+
+func describedChangeSetSummary(name, kind string, age time.Duration) *cloudformation.ChangeSetSummary {
+	summary := changeSetSummary(name, cloudformation.ChangeSetStatusCreateComplete, cloudformation.ExecutionStatusAvailable, age)
+	summary.Description = aws.String(changeSetDescription(kind, "hash"))
+
+	return summary
+}
+
+// ownChangeSet is the prefix of ChangeSets named by CloudFormationDeploy for the stack "test", missing the last
+// digit of the UUID.
+const ownChangeSet = "test-6ba7b810-9dad-11d1-80b4-00c04fd430c"
+
+func newMockChangeSetListClient() mockChangeSetListClient {
+	return mockChangeSetListClient{
+		mockPlanClient: newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", "")),
+		pages: [][]*cloudformation.ChangeSetSummary{
+			{
+				changeSetSummary(ownChangeSet+"1", cloudformation.ChangeSetStatusFailed, cloudformation.ExecutionStatusUnavailable, time.Minute),
+				changeSetSummary(ownChangeSet+"2", cloudformation.ChangeSetStatusCreateComplete, cloudformation.ExecutionStatusAvailable, time.Minute),
+				changeSetSummary(ownChangeSet+"3", cloudformation.ChangeSetStatusCreateComplete, cloudformation.ExecutionStatusAvailable, 48*time.Hour),
+			},
+			{
+				changeSetSummary(ownChangeSet+"4", cloudformation.ChangeSetStatusCreateInProgress, cloudformation.ExecutionStatusUnavailable, 48*time.Hour),
+				changeSetSummary(ownChangeSet+"5", cloudformation.ChangeSetStatusCreateComplete, cloudformation.ExecutionStatusExecuteInProgress, 48*time.Hour),
+				changeSetSummary("manual-review", cloudformation.ChangeSetStatusFailed, cloudformation.ExecutionStatusUnavailable, 48*time.Hour),
+				changeSetSummary("test-other-"+ownChangeSet[5:]+"8", cloudformation.ChangeSetStatusFailed, cloudformation.ExecutionStatusUnavailable, 48*time.Hour),
+				describedChangeSetSummary(ownChangeSet+"6", changeSetKindPlan, 48*time.Hour),
+				describedChangeSetSummary(ownChangeSet+"7", changeSetKindApproval, 48*time.Hour),
+			},
+		},
+		deleted: &[]string{},
+	}
+}
+
+func TestCloudformation_DeleteStaleChangeSets(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		maxPlanAge  time.Duration
+		deferredAge time.Duration
+		want        []string
+	}{
+		{name: "Test only failed change sets", want: []string{ownChangeSet + "1"}},
+		{
+			name:   "Test failed and old change sets",
+			maxAge: 24 * time.Hour,
+			want:   []string{ownChangeSet + "1", ownChangeSet + "3", ownChangeSet + "7"},
+		},
+		{
+			name:       "Test expired plans",
+			maxAge:     24 * time.Hour,
+			maxPlanAge: 24 * time.Hour,
+			want:       []string{ownChangeSet + "1", ownChangeSet + "3", ownChangeSet + "6", ownChangeSet + "7"},
+		},
+		{
+			name:        "Test deferred change sets are kept longer",
+			maxAge:      24 * time.Hour,
+			deferredAge: 72 * time.Hour,
+			want:        []string{ownChangeSet + "1", ownChangeSet + "3"},
+		},
+		{
+			name:        "Test expired deferred change sets",
+			maxAge:      72 * time.Hour,
+			deferredAge: 24 * time.Hour,
+			want:        []string{ownChangeSet + "1", ownChangeSet + "7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockChangeSetListClient()
+			c := &Cloudformation{CFClient: client, StackName: "test", MaxPlanAge: tt.maxPlanAge, DeferredChangeSetAge: tt.deferredAge}
+
+			got, err := c.DeleteStaleChangeSets(tt.maxAge)
+			if err != nil {
+				t.Fatalf("DeleteStaleChangeSets() unexpected error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeleteStaleChangeSets() = %v, want %v", got, tt.want)
+			}
+
+			if len(*client.deleted) != len(tt.want) || (*client.deleted)[0] != "arn:"+tt.want[0] {
+				t.Errorf("deleted change sets = %v", *client.deleted)
+			}
+		})
+	}
+}
+
+func TestCloudformation_CloudFormationDeploy_staleChangeSets(t *testing.T) {
+	client := newMockChangeSetListClient()
+	c := &Cloudformation{CFClient: client, StackName: "test", StaleChangeSetAge: 24 * time.Hour}
+
+	if err := c.CloudFormationDeploy(hashTemplate, false); err != nil {
+		t.Fatalf("CloudFormationDeploy() unexpected error = %v", err)
+	}
+
+	if len(*client.deleted) != 3 || !*client.executed {
+		t.Errorf("deleted change sets = %v, executed = %v", *client.deleted, *client.executed)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 	"github.com/cenkalti/backoff/v4"
-	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,6 +27,54 @@ type Cloudformation struct {
 	CFClient    cloudformationiface.CloudFormationAPI
 	StackName   string
 	LogrusEntry *logrus.Entry
+	// Parameters and Tags are passed to every ChangeSet, see LoadStackConfiguration.
+	Parameters []*cloudformation.Parameter
+	Tags       []*cloudformation.Tag
+	// StackPolicy is the JSON stack policy to set on the stack. It is set after creating a stack
+	// and updated before executing a ChangeSet if it differs from the deployed one.
+	StackPolicy string
+	// StackPolicyDuringUpdate temporarily overrides the stack policy while a ChangeSet is executed.
+	// It is only used if AllowStackPolicyOverride is set as well.
+	StackPolicyDuringUpdate  string
+	AllowStackPolicyOverride bool
+	// TerminationProtection enables or disables termination protection after each deployment.
+	// If nil, the termination protection of the stack is left untouched.
+	TerminationProtection *bool
+	// Guardrails are checked before the Approver is asked, see LoadGuardrailPolicy.
+	Guardrails *GuardrailPolicy
+	// Approver is asked before a ChangeSet gets executed. If nil, every ChangeSet is executed.
+	Approver Approver
+	// SkipUnchanged stores the DeploymentHash as the DeploymentHashTag on the stack and doesn't create a ChangeSet
+	// if it didn't change since the last successful deployment. Changing the tag updates the tags of all
+	// resources of the stack.
+	SkipUnchanged bool
+	// RefuseDrifted runs a drift detection before updating a stack and returns a *StackDriftError if resources
+	// were modified or deleted outside of CloudFormation.
+	RefuseDrifted bool
+	// EstimateCost adds the URL of a cost estimation of the template to every DeploymentPlan.
+	EstimateCost bool
+	// MaxPlanAge is the maximum age of a DeploymentPlan passed to Apply. If zero, plans don't expire.
+	MaxPlanAge time.Duration
+	// StaleChangeSetAge deletes failed ChangeSets and ChangeSets older than this before each deployment,
+	// see DeleteStaleChangeSets. If zero, no ChangeSets are deleted.
+	StaleChangeSetAge time.Duration
+	// DeferredChangeSetAge is the age after which DeleteStaleChangeSets deletes ChangeSets created while an
+	// Approver was set, which may have been deferred. If zero, they expire like all other ChangeSets.
+	DeferredChangeSetAge time.Duration
+	// DeploymentID identifies a deployment attempt, e.g. by the ID of a CI pipeline run. If set, retrying the
+	// deployment with the same template after a restart reuses the ChangeSet of the previous attempt.
+	DeploymentID string
+	// Resume waits for a ChangeSet created by this library for the same DeploymentHash which is already being
+	// executed, e.g. by a previous attempt which was interrupted, instead of creating a new one.
+	Resume bool
+	// Journal records every deployment done by CloudFormationDeploy, JournalMetadata is added to the records,
+	// e.g. the git SHA of the template and the user or pipeline deploying it.
+	Journal         Journal
+	JournalMetadata map[string]string
+	// IncludeNestedStacks creates ChangeSets for nested stacks as well. Their changes are included in the
+	// described ChangeSet, the events of nested stacks are logged during the execution, and a failed execution
+	// reports the resource which caused it.
+	IncludeNestedStacks bool
 }
 
 // CloudformationAPI provides an API which can be used instead of a concrete client for testing/mocking purposes.
@@ -87,8 +134,9 @@ func trimStackName(stackName string, max int) string {
 func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 	//nolint
 	ecsi := &cloudformation.ExecuteChangeSetInput{
-		ChangeSetName: aws.String(changeSetName),
-		StackName:     aws.String(c.StackName),
+		ChangeSetName:      aws.String(changeSetName),
+		ClientRequestToken: aws.String(requestToken(changeSetName)),
+		StackName:          aws.String(c.StackName),
 	}
 
 	_, err := c.CFClient.ExecuteChangeSet(ecsi)
@@ -96,6 +144,11 @@ func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 		return fmt.Errorf("error executing the ChangeSet: %w", err)
 	}
 
+	return c.waitForChangeSetExecution(changeSetName)
+}
+
+// waitForChangeSetExecution waits until the stack finished the update of an executed ChangeSet.
+func (c *Cloudformation) waitForChangeSetExecution(changeSetName string) error {
 	endRetryTimestamp := time.Now().Add(maxRetryTimeForStack)
 
 	back := &backoff.ExponentialBackOff{
@@ -108,12 +161,13 @@ func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 		Clock:               backoff.SystemClock,
 	}
 
-	var errToReturn error
-
-	err = backoff.Retry(func() error {
-		var dso *cloudformation.DescribeStacksOutput
+	var (
+		errToReturn error
+		events      *stackEventTracker
+	)
 
-		dso, err = c.CFClient.DescribeStacks(&cloudformation.DescribeStacksInput{
+	err := backoff.Retry(func() error {
+		dso, err := c.CFClient.DescribeStacks(&cloudformation.DescribeStacksInput{
 			NextToken: nil,
 			StackName: aws.String(c.StackName),
 		})
@@ -127,6 +181,16 @@ func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 			return nil
 		}
 
+		if c.IncludeNestedStacks {
+			if events == nil {
+				events = newStackEventTracker(c, dso.Stacks[0])
+			}
+
+			if err = events.poll(); err != nil {
+				c.logger().Warnf("Couldn't follow the stack events: %v", err)
+			}
+		}
+
 		stackStatus := *dso.Stacks[0].StackStatus
 		switch stackStatus {
 		case cloudformation.StackStatusUpdateComplete, cloudformation.StackStatusCreateComplete, cloudformation.StackStatusUpdateCompleteCleanupInProgress:
@@ -140,7 +204,7 @@ func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 			return fmt.Errorf("stack creation not complete yet, status: %s", stackStatus)
 		}
 
-		errToReturn = fmt.Errorf("unexpected stack status for stack %s: %s", *dso.Stacks[0].StackName, stackStatus)
+		errToReturn = &StackFailureError{StackName: *dso.Stacks[0].StackName, Status: stackStatus, Cause: events.rootCause()}
 
 		return nil
 	}, back)
@@ -153,15 +217,123 @@ func (c *Cloudformation) executeChangeSet(changeSetName string) error {
 }
 
 // CloudFormationDeploy deploys the given Cloudformation Template to the given Cloudformation Stack.
+// The deployment is recorded in the Journal if one is set.
 func (c *Cloudformation) CloudFormationDeploy(templateBody string, namedIAM bool) error {
-	changeSetType, err := c.getCreateType()
+	if c.Journal == nil {
+		return c.deploy(templateBody, namedIAM, nil)
+	}
+
+	record := c.newDeploymentRecord(templateBody, namedIAM)
+
+	return c.recordDeployment(record, c.deploy(templateBody, namedIAM, record))
+}
+
+// deploy implements CloudFormationDeploy, the ChangeSet is added to the record if it isn't nil.
+func (c *Cloudformation) deploy(templateBody string, namedIAM bool, record *DeploymentRecord) error {
+	if err := c.validateStackPolicyOptions(); err != nil {
+		return err
+	}
+
+	c.deleteStaleChangeSets()
+
+	if c.Resume {
+		resumed, err := c.resumeDeployment(templateBody, namedIAM, record)
+		if err != nil || resumed {
+			return err
+		}
+	}
+
+	cs, err := c.createChangeSet(templateBody, namedIAM, c.deploymentKind())
 	if err != nil {
 		return err
 	}
 
-	id, err := uuid.NewUUID()
+	return c.executeDeployment(cs, record)
+}
+
+// executeDeployment approves and executes the ChangeSet created for a deployment, which is nil if there are no
+// changes, and syncs the termination protection.
+func (c *Cloudformation) executeDeployment(cs *changeSet, record *DeploymentRecord) error {
+	if cs == nil {
+		return c.syncUnchangedStack()
+	}
+
+	if err := c.addChangeSetToRecord(record, cs); err != nil {
+		return err
+	}
+
+	if err := c.approveChangeSet(cs.describeInput, cs.changeSetType); err != nil {
+		return err
+	}
+
+	if err := c.executeWithStackPolicy(cs.name, cs.changeSetType); err != nil {
+		return err
+	}
+
+	return c.syncTerminationProtection()
+}
+
+// syncUnchangedStack applies the stack policy and termination protection to a stack without ChangeSet to execute,
+// as they aren't part of the template.
+func (c *Cloudformation) syncUnchangedStack() error {
+	if err := c.syncStackPolicy(); err != nil {
+		return err
+	}
+
+	return c.syncTerminationProtection()
+}
+
+// changeSet is a ChangeSet created by createChangeSet.
+type changeSet struct {
+	name          string
+	changeSetType string
+	describeInput *cloudformation.DescribeChangeSetInput
+}
+
+// createChangeSet validates the template, creates a ChangeSet and waits until it's created. It returns nil if
+// there are no changes, either because the stack is unchanged or because the ChangeSet was empty and got deleted.
+// kind is stored in the description, see DeleteStaleChangeSets.
+func (c *Cloudformation) createChangeSet(templateBody string, namedIAM bool, kind string) (*changeSet, error) {
+	if _, err := c.Validate(templateBody, namedIAM); err != nil {
+		return nil, err
+	}
+
+	hash, err := c.DeploymentHash(templateBody, namedIAM)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := c.Tags
+
+	if c.SkipUnchanged {
+		unchanged, err := c.isUnchanged(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if unchanged {
+			c.logger().Infof("Stack was already deployed with deployment hash %s. Skipping ChangeSet.", hash)
+
+			return nil, nil
+		}
+
+		tags = c.withDeploymentHashTag(hash)
+	}
+
+	changeSetType, err := c.getCreateType()
+	if err != nil {
+		return nil, err
+	}
+
+	if changeSetType == "UPDATE" {
+		if err = c.refuseDrifted(); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := c.changeSetID(hash)
 	if err != nil {
-		return fmt.Errorf("error while generating UUID %w", err)
+		return nil, err
 	}
 
 	// max stack name is 128, then we add a UUID (36 byte/char string) so the max the stackName can be is 92
@@ -173,19 +345,29 @@ func (c *Cloudformation) CloudFormationDeploy(templateBody string, namedIAM bool
 
 	//nolint
 	ccsi := &cloudformation.CreateChangeSetInput{
-		ChangeSetName: aws.String(csn),
-		ChangeSetType: aws.String(changeSetType),
-		StackName:     aws.String(sn),
-		TemplateBody:  aws.String(templateBody),
+		Capabilities:        capabilities(namedIAM),
+		ChangeSetName:       aws.String(csn),
+		ChangeSetType:       aws.String(changeSetType),
+		ClientToken:         aws.String(requestToken(csn)),
+		Description:         aws.String(changeSetDescription(kind, hash)),
+		IncludeNestedStacks: aws.Bool(c.IncludeNestedStacks),
+		Parameters:          c.Parameters,
+		StackName:           aws.String(sn),
+		Tags:                tags,
+		TemplateBody:        aws.String(templateBody),
 	}
 
-	if namedIAM {
-		ccsi.Capabilities = []*string{aws.String(cloudformation.CapabilityCapabilityNamedIam)}
-	}
+	return c.createChangeSetAndWait(ccsi)
+}
+
+// createChangeSetAndWait creates the ChangeSet and waits until it's created. It returns nil if the ChangeSet
+// was empty and got deleted.
+func (c *Cloudformation) createChangeSetAndWait(ccsi *cloudformation.CreateChangeSetInput) (*changeSet, error) {
+	csn, sn, changeSetType := aws.StringValue(ccsi.ChangeSetName), aws.StringValue(ccsi.StackName), aws.StringValue(ccsi.ChangeSetType)
 
 	ccso, err := c.CFClient.CreateChangeSet(ccsi)
 	if err != nil {
-		return fmt.Errorf("the ChangeSetType was %s error in creating ChangeSet: %w", changeSetType, err)
+		return nil, fmt.Errorf("the ChangeSetType was %s error in creating ChangeSet: %w", changeSetType, err)
 	}
 
 	//nolint
@@ -206,7 +388,7 @@ func (c *Cloudformation) CloudFormationDeploy(templateBody string, namedIAM bool
 		dcso, err2 := c.CFClient.DescribeChangeSet(dcsi)
 
 		if err2 != nil {
-			return fmt.Errorf("error describing the ChangeSet: %w", err2)
+			return nil, fmt.Errorf("error describing the ChangeSet: %w", err2)
 		}
 
 		if changeSetIsEmpty(dcso) {
@@ -217,16 +399,31 @@ func (c *Cloudformation) CloudFormationDeploy(templateBody string, namedIAM bool
 				StackName:     aws.String(sn),
 			})
 			if err3 != nil {
-				return fmt.Errorf("couldn't delete empty change set: %w", err3)
+				return nil, fmt.Errorf("couldn't delete empty change set: %w", err3)
 			}
 
-			return nil
+			return nil, nil
 		}
 
-		return fmt.Errorf("changeset is not empty but waiting for changeset completion still timed out. Error was: %w", err)
+		return nil, fmt.Errorf("changeset is not empty but waiting for changeset completion still timed out. Error was: %w", err)
+	}
+
+	return &changeSet{name: csn, changeSetType: changeSetType, describeInput: dcsi}, nil
+}
+
+// TemplateBody is implemented by generated templates, e.g. *template.Template.
+type TemplateBody interface {
+	TemplateBody() (string, error)
+}
+
+// CloudFormationDeployTemplate deploys a generated template like CloudFormationDeploy does.
+func (c *Cloudformation) CloudFormationDeployTemplate(t TemplateBody, namedIAM bool) error {
+	templateBody, err := t.TemplateBody()
+	if err != nil {
+		return fmt.Errorf("error generating template: %w", err)
 	}
 
-	return c.executeChangeSet(csn)
+	return c.CloudFormationDeploy(templateBody, namedIAM)
 }
 
 // CreateStackName creates a valid stack name from the given alarm name.
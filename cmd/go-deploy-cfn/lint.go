@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/moia-oss/go-deploy-cfn/lint"
+)
+
+func runLint(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("no templates given")
+	}
+
+	failed := false
+
+	for _, path := range fs.Args() {
+		findings, err := lint.LintFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%s:%s\n", path, f)
+		}
+
+		if lint.HasErrors(findings) || (*strict && len(findings) > 0) {
+			failed = true
+		}
+	}
+
+	if failed {
+		return errFindings
+	}
+
+	return nil
+}
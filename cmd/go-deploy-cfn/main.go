@@ -0,0 +1,77 @@
+// Command go-deploy-cfn provides the checks of the go-deploy-cfn library on the command line.
+//
+// Usage:
+//
+//	go-deploy-cfn lint [-strict] template...
+//	go-deploy-cfn plan -stack name [-parameters file] [-region region] [-named-iam] [-cost] [-format table|markdown|json] [-out file] template
+//	go-deploy-cfn apply [-region region] [-max-age duration] plan-file
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errFindings signals that a command finished but found problems, which only changes the exit code.
+var errFindings = errors.New("problems found")
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "lint", description: "check templates for common mistakes without calling AWS", run: runLint},
+	{name: "plan", description: "create a ChangeSet and show the changes without executing it", run: runPlan},
+	{name: "apply", description: "execute the ChangeSet of a plan file unless the stack changed since", run: runApply},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(args[1:], stdout, stderr)
+
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errFindings):
+			return 1
+		case errors.Is(err, flag.ErrHelp):
+			return 2
+		}
+
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+
+		return 2
+	}
+
+	usage(stderr)
+
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: go-deploy-cfn <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"valid.yaml":   "Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n",
+		"warning.yaml": "Parameters:\n  Env:\n    Type: String\nResources:\n  Topic:\n    Type: AWS::SNS::Topic\n",
+		"error.yaml":   "Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n    DependsOn: Missing\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     int
+		contains string
+	}{
+		{name: "Test valid template", args: []string{"lint", "valid.yaml"}, want: 0},
+		{name: "Test warnings pass", args: []string{"lint", "warning.yaml"}, want: 0, contains: "[W001]"},
+		{name: "Test warnings fail in strict mode", args: []string{"lint", "-strict", "warning.yaml"}, want: 1},
+		{name: "Test errors fail", args: []string{"lint", "error.yaml"}, want: 1, contains: "error.yaml:4:16: error [E003]"},
+		{name: "Test unknown command", args: []string{"foo"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			args := append([]string{}, tt.args...)
+			for i, a := range args {
+				if strings.HasSuffix(a, ".yaml") {
+					args[i] = filepath.Join(dir, a)
+				}
+			}
+
+			if got := run(args, &stdout, &stderr); got != tt.want {
+				t.Errorf("run() = %d, want %d, stderr: %s", got, tt.want, stderr.String())
+			}
+
+			if !strings.Contains(stdout.String(), tt.contains) {
+				t.Errorf("run() output %q does not contain %q", stdout.String(), tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/sirupsen/logrus"
+
+	godeploycfn "github.com/moia-oss/go-deploy-cfn"
+)
+
+// newCFClient creates the CloudFormation client of commands calling AWS, it's replaced in tests.
+var newCFClient = func(region string) (cloudformationiface.CloudFormationAPI, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: optionalRegion(region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS session: %w", err)
+	}
+
+	return cloudformation.New(sess), nil
+}
+
+func optionalRegion(region string) *string {
+	if region == "" {
+		return nil
+	}
+
+	return aws.String(region)
+}
+
+func runPlan(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	stackName := fs.String("stack", "", "name of the stack (required)")
+	parameters := fs.String("parameters", "", "parameter file, see LoadStackConfiguration")
+	region := fs.String("region", "", "AWS region, defaults to the region of the AWS configuration")
+	namedIAM := fs.Bool("named-iam", false, "grant CAPABILITY_NAMED_IAM")
+	cost := fs.Bool("cost", false, "include the URL of a cost estimation")
+	format := fs.String("format", "table", "output format: table, markdown or json")
+	out := fs.String("out", "", "write the plan to this file, so it can be executed with apply")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *stackName == "" || fs.NArg() != 1 {
+		return errors.New("usage: plan -stack name [flags] template")
+	}
+
+	renderer, err := planRenderer(*format)
+	if err != nil {
+		return err
+	}
+
+	templateBody, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading template: %w", err)
+	}
+
+	client, err := newCFClient(*region)
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(stderr)
+
+	c := &godeploycfn.Cloudformation{
+		CFClient:     client,
+		StackName:    *stackName,
+		LogrusEntry:  logrus.NewEntry(logger),
+		EstimateCost: *cost,
+	}
+
+	if *parameters != "" {
+		var cfg *godeploycfn.StackConfiguration
+		if cfg, err = godeploycfn.LoadStackConfiguration(*parameters); err != nil {
+			return err
+		}
+
+		cfg.Apply(c)
+	}
+
+	plan, err := c.Plan(string(templateBody), *namedIAM)
+	if err != nil {
+		return err
+	}
+
+	if *out != "" {
+		if err = plan.Save(*out); err != nil {
+			return err
+		}
+	}
+
+	return writePlan(stdout, plan, renderer)
+}
+
+func runApply(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	region := fs.String("region", "", "AWS region, defaults to the region of the AWS configuration")
+	maxAge := fs.Duration("max-age", 0, "refuse plans older than this, e.g. 1h")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: apply [flags] plan-file")
+	}
+
+	plan, err := godeploycfn.LoadPlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := newCFClient(*region)
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(stderr)
+
+	c := &godeploycfn.Cloudformation{
+		CFClient:    client,
+		StackName:   plan.StackName,
+		LogrusEntry: logrus.NewEntry(logger),
+		MaxPlanAge:  *maxAge,
+	}
+
+	if err = c.Apply(plan); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Applied plan for stack %s.\n", plan.StackName)
+
+	return nil
+}
+
+// planRenderer returns the renderer of the format, or nil for JSON which prints the whole plan.
+func planRenderer(format string) (godeploycfn.ChangeSetRenderer, error) {
+	switch format {
+	case "table":
+		return godeploycfn.TableRenderer{}, nil
+	case "markdown":
+		return godeploycfn.MarkdownRenderer{}, nil
+	case "json":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func writePlan(w io.Writer, plan *godeploycfn.DeploymentPlan, renderer godeploycfn.ChangeSetRenderer) error {
+	if renderer == nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(plan)
+	}
+
+	if !plan.HasChanges() {
+		fmt.Fprintf(w, "No changes for stack %s.\n", plan.StackName)
+	} else if err := renderer.Render(w, plan.ChangeSet); err != nil {
+		return err
+	}
+
+	if plan.EstimatedCostURL != "" {
+		fmt.Fprintf(w, "\nEstimated cost: %s\n", plan.EstimatedCostURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockCFClient struct {
+	cloudformationiface.CloudFormationAPI
+}
+
+func (mockCFClient) ValidateTemplate(*cloudformation.ValidateTemplateInput) (*cloudformation.ValidateTemplateOutput, error) {
+	return &cloudformation.ValidateTemplateOutput{}, nil
+}
+
+func (mockCFClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{
+		StackName:   input.StackName,
+		StackStatus: aws.String(cloudformation.StackStatusUpdateComplete),
+	}}}, nil
+}
+
+func (mockCFClient) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	return &cloudformation.CreateChangeSetOutput{Id: input.ChangeSetName}, nil
+}
+
+func (mockCFClient) WaitUntilChangeSetCreateCompleteWithContext(aws.Context, *cloudformation.DescribeChangeSetInput,
+	...request.WaiterOption,
+) error {
+	return nil
+}
+
+func (mockCFClient) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	return &cloudformation.DescribeChangeSetOutput{
+		ChangeSetId:     input.ChangeSetName,
+		ChangeSetName:   input.ChangeSetName,
+		StackName:       input.StackName,
+		Status:          aws.String(cloudformation.ChangeSetStatusCreateComplete),
+		ExecutionStatus: aws.String(cloudformation.ExecutionStatusAvailable),
+		Changes: []*cloudformation.Change{{ResourceChange: &cloudformation.ResourceChange{
+			Action:            aws.String(cloudformation.ChangeActionAdd),
+			LogicalResourceId: aws.String("Topic"),
+			ResourceType:      aws.String("AWS::SNS::Topic"),
+		}}},
+	}, nil
+}
+
+func (mockCFClient) ExecuteChangeSet(*cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (mockCFClient) EstimateTemplateCost(*cloudformation.EstimateTemplateCostInput) (*cloudformation.EstimateTemplateCostOutput, error) {
+	return &cloudformation.EstimateTemplateCostOutput{Url: aws.String("https://calculator.example/estimate")}, nil
+}
+
+func TestRunPlan(t *testing.T) {
+	newCFClient = func(string) (cloudformationiface.CloudFormationAPI, error) {
+		return mockCFClient{}, nil
+	}
+
+	dir := t.TempDir()
+	template := filepath.Join(dir, "template.yaml")
+	planFile := filepath.Join(dir, "plan.json")
+
+	if err := os.WriteFile(template, []byte("Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     int
+		contains []string
+	}{
+		{name: "Test table", args: []string{"plan", "-stack", "test", template}, contains: []string{"Topic", "AWS::SNS::Topic"}},
+		{
+			name:     "Test JSON with cost",
+			args:     []string{"plan", "-stack", "test", "-cost", "-format", "json", template},
+			contains: []string{`"logicalResourceId": "Topic"`, `"estimatedCostUrl": "https://calculator.example/estimate"`},
+		},
+		{
+			name:     "Test markdown with cost",
+			args:     []string{"plan", "-stack", "test", "-cost", "-format", "markdown", template},
+			contains: []string{"`Topic`", "Estimated cost: https://calculator.example/estimate"},
+		},
+		{name: "Test plan file", args: []string{"plan", "-stack", "test", "-out", planFile, template}},
+		{name: "Test apply", args: []string{"apply", "-max-age", "1h", planFile}, contains: []string{"Applied plan for stack test."}},
+		{name: "Test missing stack", args: []string{"plan", template}, want: 2},
+		{name: "Test unknown format", args: []string{"plan", "-stack", "test", "-format", "xml", template}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if got := run(tt.args, &stdout, &stderr); got != tt.want {
+				t.Errorf("run() = %d, want %d, stderr: %s", got, tt.want, stderr.String())
+			}
+
+			for _, c := range tt.contains {
+				if !strings.Contains(stdout.String(), c) {
+					t.Errorf("run() output %q does not contain %q", stdout.String(), c)
+				}
+			}
+		})
+	}
+}
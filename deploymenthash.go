@@ -0,0 +1,112 @@
+package godeploycfn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// DeploymentHashTag is the stack tag holding the DeploymentHash of the last deployment if SkipUnchanged is set.
+const DeploymentHashTag = "go-deploy-cfn:deployment-hash"
+
+// unchangedStackStatuses are the statuses in which the tags of a stack describe a successful deployment.
+var unchangedStackStatuses = map[string]bool{
+	cloudformation.StackStatusCreateComplete:         true,
+	cloudformation.StackStatusUpdateComplete:         true,
+	cloudformation.StackStatusUpdateRollbackComplete: true,
+	cloudformation.StackStatusImportComplete:         true,
+	cloudformation.StackStatusImportRollbackComplete: true,
+}
+
+type deploymentHashInput struct {
+	Template     interface{}         `json:"template"`
+	Parameters   []map[string]string `json:"parameters"`
+	Tags         map[string]string   `json:"tags"`
+	Capabilities []string            `json:"capabilities"`
+}
+
+// DeploymentHash returns a hash of everything that is deployed by CloudFormationDeploy: the normalized template,
+// Parameters, Tags and capabilities. Formatting and key order of the template don't change the hash.
+func (c *Cloudformation) DeploymentHash(templateBody string, namedIAM bool) (string, error) {
+	template, err := normalizeTemplate(templateBody)
+	if err != nil {
+		return "", err
+	}
+
+	input := deploymentHashInput{
+		Template:     template,
+		Parameters:   []map[string]string{},
+		Tags:         map[string]string{},
+		Capabilities: aws.StringValueSlice(capabilities(namedIAM)),
+	}
+
+	for _, p := range c.Parameters {
+		param := map[string]string{"key": aws.StringValue(p.ParameterKey)}
+
+		if aws.BoolValue(p.UsePreviousValue) {
+			param["usePreviousValue"] = "true"
+		} else {
+			param["value"] = aws.StringValue(p.ParameterValue)
+		}
+
+		input.Parameters = append(input.Parameters, param)
+	}
+
+	sort.Slice(input.Parameters, func(i, j int) bool {
+		return input.Parameters[i]["key"] < input.Parameters[j]["key"]
+	})
+
+	for _, t := range c.Tags {
+		if aws.StringValue(t.Key) != DeploymentHashTag {
+			input.Tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+	}
+
+	// json.Marshal sorts map keys, which makes the hash independent of the order in the template.
+	b, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("error computing deployment hash: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// isUnchanged returns true if the stack was successfully deployed with the given DeploymentHash.
+func (c *Cloudformation) isUnchanged(hash string) (bool, error) {
+	stack, err := c.describeStack()
+	if err != nil || stack == nil {
+		return false, err
+	}
+
+	if !unchangedStackStatuses[aws.StringValue(stack.StackStatus)] {
+		return false, nil
+	}
+
+	for _, t := range stack.Tags {
+		if aws.StringValue(t.Key) == DeploymentHashTag {
+			return aws.StringValue(t.Value) == hash, nil
+		}
+	}
+
+	return false, nil
+}
+
+// withDeploymentHashTag returns Tags with the DeploymentHashTag set to hash.
+func (c *Cloudformation) withDeploymentHashTag(hash string) []*cloudformation.Tag {
+	tags := make([]*cloudformation.Tag, 0, len(c.Tags)+1)
+
+	for _, t := range c.Tags {
+		if aws.StringValue(t.Key) != DeploymentHashTag {
+			tags = append(tags, t)
+		}
+	}
+
+	return append(tags, &cloudformation.Tag{Key: aws.String(DeploymentHashTag), Value: aws.String(hash)})
+}
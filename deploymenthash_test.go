@@ -0,0 +1,141 @@
+package godeploycfn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+var errChangeSetCreated = errors.New("change set created")
+
+type mockDeploymentHashClient struct {
+	cloudformationiface.CloudFormationAPI
+	status string
+	hash   string
+	tags   *[]*cloudformation.Tag
+}
+
+func (m mockDeploymentHashClient) ValidateTemplate(*cloudformation.ValidateTemplateInput) (*cloudformation.ValidateTemplateOutput, error) {
+	return &cloudformation.ValidateTemplateOutput{}, nil
+}
+
+func (m mockDeploymentHashClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{{
+			StackName:   input.StackName,
+			StackStatus: aws.String(m.status),
+			Tags:        []*cloudformation.Tag{{Key: aws.String(DeploymentHashTag), Value: aws.String(m.hash)}},
+		}},
+	}, nil
+}
+
+func (m mockDeploymentHashClient) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	*m.tags = input.Tags
+
+	return nil, errChangeSetCreated
+}
+
+const hashTemplate = `Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: alerts
+      TopicName: !Sub "${AWS::StackName}-alerts"
+`
+
+func TestCloudformation_DeploymentHash(t *testing.T) {
+	base := &Cloudformation{
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("A"), ParameterValue: aws.String("1")},
+			{ParameterKey: aws.String("B"), UsePreviousValue: aws.Bool(true)},
+		},
+		Tags: []*cloudformation.Tag{{Key: aws.String("team"), Value: aws.String("ops")}},
+	}
+
+	want, err := base.DeploymentHash(hashTemplate, false)
+	if err != nil {
+		t.Fatalf("DeploymentHash() unexpected error = %v", err)
+	}
+
+	reordered := `{"Resources": {"Topic": {"Properties": {"TopicName": {"Fn::Sub": "${AWS::StackName}-alerts"},
+		"DisplayName": "alerts"}, "Type": "AWS::SNS::Topic"}}}`
+
+	tests := []struct {
+		name      string
+		c         *Cloudformation
+		body      string
+		namedIAM  bool
+		wantEqual bool
+	}{
+		{name: "Test formatting and order are ignored", c: &Cloudformation{
+			Parameters: []*cloudformation.Parameter{base.Parameters[1], base.Parameters[0]},
+			Tags:       append(base.withDeploymentHashTag("old"), base.Tags...),
+		}, body: reordered, wantEqual: true},
+		{name: "Test changed template", c: base, body: hashTemplate + "Outputs: {}\n"},
+		{name: "Test capabilities", c: base, body: hashTemplate, namedIAM: true},
+		{name: "Test changed parameter", c: &Cloudformation{
+			Parameters: []*cloudformation.Parameter{{ParameterKey: aws.String("A"), ParameterValue: aws.String("2")}, base.Parameters[1]},
+			Tags:       base.Tags,
+		}, body: hashTemplate},
+		{name: "Test changed tags", c: &Cloudformation{Parameters: base.Parameters}, body: hashTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.DeploymentHash(tt.body, tt.namedIAM)
+			if err != nil {
+				t.Fatalf("DeploymentHash() unexpected error = %v", err)
+			}
+
+			if (got == want) != tt.wantEqual {
+				t.Errorf("DeploymentHash() = %s, base hash %s, want equal %v", got, want, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestCloudformation_CloudFormationDeploy_skipUnchanged(t *testing.T) {
+	hash, err := (&Cloudformation{}).DeploymentHash(hashTemplate, false)
+	if err != nil {
+		t.Fatalf("DeploymentHash() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		status        string
+		hash          string
+		wantChangeSet bool
+	}{
+		{name: "Test unchanged stack is skipped", status: cloudformation.StackStatusUpdateComplete, hash: hash},
+		{name: "Test changed stack", status: cloudformation.StackStatusUpdateComplete, hash: "old", wantChangeSet: true},
+		{name: "Test failed stack", status: cloudformation.StackStatusRollbackComplete, hash: hash, wantChangeSet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockDeploymentHashClient{status: tt.status, hash: tt.hash, tags: new([]*cloudformation.Tag)}
+			c := &Cloudformation{CFClient: client, StackName: "test", SkipUnchanged: true}
+
+			err := c.CloudFormationDeploy(hashTemplate, false)
+			if !tt.wantChangeSet {
+				if err != nil {
+					t.Errorf("CloudFormationDeploy() unexpected error = %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, errChangeSetCreated) {
+				t.Fatalf("CloudFormationDeploy() error = %v, want ChangeSet to be created", err)
+			}
+
+			tags := *client.tags
+			if len(tags) != 1 || aws.StringValue(tags[0].Key) != DeploymentHashTag || aws.StringValue(tags[0].Value) != hash {
+				t.Errorf("CreateChangeSet() tags = %v, want deployment hash %s", tags, hash)
+			}
+		})
+	}
+}
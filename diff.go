@@ -0,0 +1,374 @@
+package godeploycfn
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"gopkg.in/yaml.v3"
+
+	"github.com/moia-oss/go-deploy-cfn/internal/cfnyaml"
+)
+
+// DiffAction describes how a part of a template differs from the deployed one.
+type DiffAction string
+
+// The possible DiffActions.
+const (
+	DiffAdded    DiffAction = "Added"
+	DiffRemoved  DiffAction = "Removed"
+	DiffModified DiffAction = "Modified"
+)
+
+// templateSections is the usual order of the sections of a template.
+var templateSections = []string{
+	"AWSTemplateFormatVersion", "Description", "Metadata", "Transform", "Parameters",
+	"Rules", "Mappings", "Conditions", "Resources", "Outputs",
+}
+
+// namedSections are the sections which are compared entry by entry.
+var namedSections = map[string]bool{
+	"Parameters": true, "Rules": true, "Mappings": true, "Conditions": true, "Resources": true, "Outputs": true,
+}
+
+// TemplateDiff is the structural difference between the deployed and a local template.
+type TemplateDiff struct {
+	Entries []TemplateDiffEntry `json:"entries"`
+}
+
+// TemplateDiffEntry is a changed section, or a changed entry like a resource of a section.
+type TemplateDiffEntry struct {
+	Section string `json:"section"`
+	// Name is the logical ID of the changed entry, it's empty if the whole section changed.
+	Name     string      `json:"name,omitempty"`
+	Action   DiffAction  `json:"action"`
+	Deployed interface{} `json:"deployed,omitempty"`
+	Local    interface{} `json:"local,omitempty"`
+	// Changes are the changed values of modified entries.
+	Changes []ValueChange `json:"changes,omitempty"`
+}
+
+// ValueChange is a changed value of an entry, Path is like "Properties.Tags[0].Value".
+type ValueChange struct {
+	Path     string      `json:"path"`
+	Action   DiffAction  `json:"action"`
+	Deployed interface{} `json:"deployed,omitempty"`
+	Local    interface{} `json:"local,omitempty"`
+}
+
+// IsEmpty returns true if the templates don't differ.
+func (d *TemplateDiff) IsEmpty() bool {
+	return len(d.Entries) == 0
+}
+
+// String returns the diff in unified format.
+func (d *TemplateDiff) String() string {
+	var sb strings.Builder
+
+	_ = d.WriteUnified(&sb)
+
+	return sb.String()
+}
+
+// WriteUnified writes the diff in unified format, with a hunk for every changed entry.
+func (d *TemplateDiff) WriteUnified(w io.Writer) error {
+	if d.IsEmpty() {
+		return nil
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("--- deployed\n+++ local\n")
+
+	for _, e := range d.Entries {
+		header := e.Section
+		if e.Name != "" {
+			header += "." + e.Name
+		}
+
+		fmt.Fprintf(&sb, "@@ %s (%s) @@\n", header, strings.ToLower(string(e.Action)))
+
+		for _, line := range diffLines(yamlLines(e.Deployed), yamlLines(e.Local)) {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+// DiffTemplate compares templateBody with the template of the deployed stack. If the stack does not exist,
+// everything is added.
+func (c *Cloudformation) DiffTemplate(templateBody string) (*TemplateDiff, error) {
+	deployed, err := c.deployedTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffTemplates(deployed, templateBody)
+}
+
+// deployedTemplate returns the template the stack was deployed with, or an empty string if it does not exist.
+func (c *Cloudformation) deployedTemplate() (string, error) {
+	out, err := c.CFClient.GetTemplate(&cloudformation.GetTemplateInput{
+		StackName:     aws.String(c.StackName),
+		TemplateStage: aws.String(cloudformation.TemplateStageOriginal),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("error getting deployed template: %w", err)
+	}
+
+	return aws.StringValue(out.TemplateBody), nil
+}
+
+// DiffTemplates compares two JSON or YAML templates. Formatting, key order and the short-form tags
+// of intrinsic functions are ignored.
+func DiffTemplates(deployedBody, localBody string) (*TemplateDiff, error) {
+	deployed, err := normalizeTemplate(deployedBody)
+	if err != nil {
+		return nil, fmt.Errorf("deployed template: %w", err)
+	}
+
+	local, err := normalizeTemplate(localBody)
+	if err != nil {
+		return nil, fmt.Errorf("local template: %w", err)
+	}
+
+	diff := &TemplateDiff{}
+
+	for _, section := range sectionOrder(deployed, local) {
+		oldSection, inDeployed := deployed[section]
+		newSection, inLocal := local[section]
+
+		oldEntries, oldIsMap := oldSection.(map[string]interface{})
+		newEntries, newIsMap := newSection.(map[string]interface{})
+
+		if !namedSections[section] || (inDeployed && !oldIsMap) || (inLocal && !newIsMap) {
+			if e, changed := diffEntry(section, "", oldSection, inDeployed, newSection, inLocal); changed {
+				diff.Entries = append(diff.Entries, e)
+			}
+
+			continue
+		}
+
+		for _, name := range unionKeys(oldEntries, newEntries) {
+			oldEntry, inOld := oldEntries[name]
+			newEntry, inNew := newEntries[name]
+
+			if e, changed := diffEntry(section, name, oldEntry, inOld, newEntry, inNew); changed {
+				diff.Entries = append(diff.Entries, e)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+func normalizeTemplate(body string) (map[string]interface{}, error) {
+	if strings.TrimSpace(body) == "" {
+		return map[string]interface{}{}, nil
+	}
+
+	root, err := cfnyaml.Parse([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := cfnyaml.Normalize(root)
+	if err != nil {
+		return nil, err
+	}
+
+	m, _ := normalizeNumbers(v).(map[string]interface{})
+
+	return m, nil
+}
+
+// normalizeNumbers converts all numbers to float64, so 1 and 1.0 are equal.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+
+	return v
+}
+
+func sectionOrder(deployed, local map[string]interface{}) []string {
+	known := map[string]bool{}
+	order := append([]string{}, templateSections...)
+
+	for _, s := range templateSections {
+		known[s] = true
+	}
+
+	for _, s := range unionKeys(deployed, local) {
+		if !known[s] {
+			order = append(order, s)
+		}
+	}
+
+	return order
+}
+
+func diffEntry(section, name string, old interface{}, inOld bool, new interface{}, inNew bool) (TemplateDiffEntry, bool) {
+	e := TemplateDiffEntry{Section: section, Name: name, Deployed: old, Local: new}
+
+	switch {
+	case !inOld && !inNew:
+		return e, false
+	case !inOld:
+		e.Action = DiffAdded
+	case !inNew:
+		e.Action = DiffRemoved
+	case reflect.DeepEqual(old, new):
+		return e, false
+	default:
+		e.Action = DiffModified
+
+		// Values which aren't compared key by key have no changes apart from the entry itself.
+		if changes := diffValues("", old, new, nil); changes[0].Path != "" {
+			e.Changes = changes
+		}
+	}
+
+	return e, true
+}
+
+func diffValues(path string, old, new interface{}, changes []ValueChange) []ValueChange {
+	if reflect.DeepEqual(old, new) {
+		return changes
+	}
+
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+
+	if oldIsMap && newIsMap {
+		for _, k := range unionKeys(oldMap, newMap) {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+
+			o, inOld := oldMap[k]
+			n, inNew := newMap[k]
+
+			switch {
+			case !inOld:
+				changes = append(changes, ValueChange{Path: p, Action: DiffAdded, Local: n})
+			case !inNew:
+				changes = append(changes, ValueChange{Path: p, Action: DiffRemoved, Deployed: o})
+			default:
+				changes = diffValues(p, o, n, changes)
+			}
+		}
+
+		return changes
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+
+	if oldIsList && newIsList && len(oldList) == len(newList) {
+		for i := range oldList {
+			changes = diffValues(fmt.Sprintf("%s[%d]", path, i), oldList[i], newList[i], changes)
+		}
+
+		return changes
+	}
+
+	return append(changes, ValueChange{Path: path, Action: DiffModified, Deployed: old, Local: new})
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+
+	for k := range a {
+		keys = append(keys, k)
+	}
+
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func yamlLines(v interface{}) []string {
+	if v == nil {
+		return nil
+	}
+
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return []string{fmt.Sprint(v)}
+	}
+
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+}
+
+// diffLines returns the lines of a and b prefixed with "-", "+" or " ", based on their longest common subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,162 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockTemplateClient struct {
+	cloudformationiface.CloudFormationAPI
+	body string
+	err  error
+}
+
+func (m mockTemplateClient) GetTemplate(input *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	if aws.StringValue(input.TemplateStage) != cloudformation.TemplateStageOriginal {
+		return nil, errors.New("unexpected template stage")
+	}
+
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &cloudformation.GetTemplateOutput{TemplateBody: aws.String(m.body)}, nil
+}
+
+const deployedDiffTemplate = `{
+  "Description": "alarms",
+  "Resources": {
+    "Topic": {"Type": "AWS::SNS::Topic", "Properties": {"DisplayName": "alerts"}},
+    "Queue": {"Type": "AWS::SQS::Queue"},
+    "Subscription": {
+      "Type": "AWS::SNS::Subscription",
+      "Properties": {"TopicArn": {"Ref": "Topic"}, "Endpoint": {"Fn::GetAtt": ["Queue", "Arn"]}, "Protocol": "sqs"}
+    }
+  }
+}`
+
+func TestDiffTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		local string
+		want  []TemplateDiffEntry
+	}{
+		{
+			name: "Test short form and ordering are ignored",
+			local: `Resources:
+  Subscription:
+    Type: AWS::SNS::Subscription
+    Properties:
+      Protocol: sqs
+      Endpoint: !GetAtt Queue.Arn
+      TopicArn: !Ref Topic
+  Queue:
+    Type: AWS::SQS::Queue
+  Topic:
+    Properties:
+      DisplayName: alerts
+    Type: AWS::SNS::Topic
+Description: alarms
+`,
+		},
+		{
+			name: "Test changed sections and resources",
+			local: `Description: lambda alarms
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: critical
+      FifoTopic: true
+  Subscription:
+    Type: AWS::SNS::Subscription
+    Properties:
+      TopicArn: !Ref Topic
+      Endpoint: !GetAtt Queue2.Arn
+      Protocol: sqs
+  Queue2:
+    Type: AWS::SQS::Queue
+`,
+			want: []TemplateDiffEntry{
+				{Section: "Description", Action: DiffModified},
+				{Section: "Resources", Name: "Queue", Action: DiffRemoved},
+				{Section: "Resources", Name: "Queue2", Action: DiffAdded},
+				{Section: "Resources", Name: "Subscription", Action: DiffModified, Changes: []ValueChange{
+					{Path: "Properties.Endpoint.Fn::GetAtt[0]", Action: DiffModified, Deployed: "Queue", Local: "Queue2"},
+				}},
+				{Section: "Resources", Name: "Topic", Action: DiffModified, Changes: []ValueChange{
+					{Path: "Properties.DisplayName", Action: DiffModified, Deployed: "alerts", Local: "critical"},
+					{Path: "Properties.FifoTopic", Action: DiffAdded, Local: true},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffTemplates(deployedDiffTemplate, tt.local)
+			if err != nil {
+				t.Fatalf("DiffTemplates() unexpected error = %v", err)
+			}
+
+			if len(got.Entries) != len(tt.want) {
+				t.Fatalf("DiffTemplates() = %+v, want %d entries", got.Entries, len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				e := got.Entries[i]
+				if e.Section != want.Section || e.Name != want.Name || e.Action != want.Action ||
+					!reflect.DeepEqual(e.Changes, want.Changes) {
+					t.Errorf("entry %d = %+v, want %+v", i, e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateDiff_WriteUnified(t *testing.T) {
+	diff, err := DiffTemplates("Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n    Properties:\n      DisplayName: a\n",
+		"Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n    Properties:\n      DisplayName: b\n")
+	if err != nil {
+		t.Fatalf("DiffTemplates() unexpected error = %v", err)
+	}
+
+	want := `--- deployed
++++ local
+@@ Resources.Topic (modified) @@
+ Properties:
+-    DisplayName: a
++    DisplayName: b
+ Type: AWS::SNS::Topic
+`
+	if got := diff.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCloudformation_DiffTemplate(t *testing.T) {
+	c := &Cloudformation{CFClient: mockTemplateClient{body: deployedDiffTemplate}, StackName: "test"}
+
+	diff, err := c.DiffTemplate(deployedDiffTemplate)
+	if err != nil || !diff.IsEmpty() {
+		t.Errorf("DiffTemplate() = %v, %v, want empty diff", diff, err)
+	}
+
+	c.CFClient = mockTemplateClient{err: awserr.New("ValidationError", "Stack with id test does not exist", nil)}
+
+	diff, err = c.DiffTemplate("Resources:\n  Queue:\n    Type: AWS::SQS::Queue\n")
+	if err != nil {
+		t.Fatalf("DiffTemplate() unexpected error = %v", err)
+	}
+
+	if len(diff.Entries) != 1 || diff.Entries[0].Action != DiffAdded || !strings.Contains(diff.String(), "+Type: AWS::SQS::Queue") {
+		t.Errorf("DiffTemplate() = %v, want added queue", diff)
+	}
+}
@@ -0,0 +1,220 @@
+package godeploycfn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// DriftReport is the result of a drift detection of a stack.
+type DriftReport struct {
+	StackName   string `json:"stackName"`
+	DetectionID string `json:"detectionId"`
+	// Status is the StackDriftStatus, e.g. DRIFTED or IN_SYNC.
+	Status string `json:"status"`
+	// DetectionStatusReason explains why the detection failed for some resources.
+	DetectionStatusReason string `json:"detectionStatusReason,omitempty"`
+	// Resources are the modified and deleted resources.
+	Resources []ResourceDrift `json:"resources,omitempty"`
+}
+
+// ResourceDrift is a resource which was modified or deleted outside of CloudFormation.
+type ResourceDrift struct {
+	LogicalResourceID  string `json:"logicalResourceId"`
+	PhysicalResourceID string `json:"physicalResourceId,omitempty"`
+	ResourceType       string `json:"resourceType"`
+	// Status is MODIFIED or DELETED.
+	Status     string          `json:"status"`
+	Properties []PropertyDrift `json:"properties,omitempty"`
+}
+
+// PropertyDrift is a property whose actual value differs from the one in the template.
+type PropertyDrift struct {
+	Path string `json:"path"`
+	// DifferenceType is ADD, REMOVE or NOT_EQUAL.
+	DifferenceType string `json:"differenceType"`
+	Expected       string `json:"expected,omitempty"`
+	Actual         string `json:"actual,omitempty"`
+}
+
+// HasDrifted returns true if resources of the stack were modified or deleted.
+func (r *DriftReport) HasDrifted() bool {
+	return r.Status == cloudformation.StackDriftStatusDrifted
+}
+
+// String lists the drifted resources and properties.
+func (r *DriftReport) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Stack %s is %s", r.StackName, r.Status)
+
+	if len(r.Resources) > 0 {
+		fmt.Fprintf(&sb, ", %d drifted resources", len(r.Resources))
+	}
+
+	sb.WriteString(".\n")
+
+	for _, res := range r.Resources {
+		fmt.Fprintf(&sb, "%s %s (%s %s)\n", res.Status, res.LogicalResourceID, res.ResourceType, res.PhysicalResourceID)
+
+		for _, p := range res.Properties {
+			fmt.Fprintf(&sb, "  %s %s: expected %s, actual %s\n", p.DifferenceType, p.Path, p.Expected, p.Actual)
+		}
+	}
+
+	return sb.String()
+}
+
+// StackDriftError is returned by CloudFormationDeploy if RefuseDrifted is set and the stack has drifted.
+type StackDriftError struct {
+	Report *DriftReport
+}
+
+func (e *StackDriftError) Error() string {
+	resources := make([]string, 0, len(e.Report.Resources))
+	for _, r := range e.Report.Resources {
+		resources = append(resources, fmt.Sprintf("%s (%s)", r.LogicalResourceID, r.Status))
+	}
+
+	return fmt.Sprintf("stack %s has drifted: %s", e.Report.StackName, strings.Join(resources, ", "))
+}
+
+// DetectDrift runs a drift detection on the stack, waits for it to finish and returns the modified and deleted
+// resources. If the detection failed for some resources, the report contains the results of the others.
+func (c *Cloudformation) DetectDrift() (*DriftReport, error) {
+	out, err := c.CFClient.DetectStackDrift(&cloudformation.DetectStackDriftInput{
+		StackName: aws.String(c.StackName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error starting drift detection: %w", err)
+	}
+
+	detectionID := aws.StringValue(out.StackDriftDetectionId)
+
+	status, err := c.waitForDriftDetection(detectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{
+		StackName:   c.StackName,
+		DetectionID: detectionID,
+		Status:      aws.StringValue(status.StackDriftStatus),
+	}
+
+	if aws.StringValue(status.DetectionStatus) == cloudformation.StackDriftDetectionStatusDetectionFailed {
+		report.DetectionStatusReason = aws.StringValue(status.DetectionStatusReason)
+		c.logger().Warnf("Drift detection failed for some resources: %s", report.DetectionStatusReason)
+	}
+
+	if report.Resources, err = c.resourceDrifts(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (c *Cloudformation) waitForDriftDetection(detectionID string) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	back := &backoff.ExponentialBackOff{
+		InitialInterval:     initialRetryPeriod,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         maxRetryInterval,
+		MaxElapsedTime:      maxRetryTimeForStack,
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}
+
+	var status *cloudformation.DescribeStackDriftDetectionStatusOutput
+
+	err := backoff.Retry(func() error {
+		var err error
+
+		status, err = c.CFClient.DescribeStackDriftDetectionStatus(&cloudformation.DescribeStackDriftDetectionStatusInput{
+			StackDriftDetectionId: aws.String(detectionID),
+		})
+		if err != nil {
+			return fmt.Errorf("encountered an error when describing the drift detection status: %w", err)
+		}
+
+		if aws.StringValue(status.DetectionStatus) == cloudformation.StackDriftDetectionStatusDetectionInProgress {
+			c.logger().Infof("Drift detection %s still in progress. Will check again.", detectionID)
+
+			return fmt.Errorf("drift detection not complete yet")
+		}
+
+		return nil
+	}, back)
+	if err != nil {
+		return nil, fmt.Errorf("maximum wait time of %s for drift detection %s has passed: %w",
+			maxRetryTimeForStack, detectionID, err)
+	}
+
+	return status, nil
+}
+
+func (c *Cloudformation) resourceDrifts() ([]ResourceDrift, error) {
+	var drifts []ResourceDrift
+
+	input := &cloudformation.DescribeStackResourceDriftsInput{
+		StackName: aws.String(c.StackName),
+		StackResourceDriftStatusFilters: aws.StringSlice([]string{
+			cloudformation.StackResourceDriftStatusModified,
+			cloudformation.StackResourceDriftStatusDeleted,
+		}),
+	}
+
+	for {
+		out, err := c.CFClient.DescribeStackResourceDrifts(input)
+		if err != nil {
+			return nil, fmt.Errorf("error describing resource drifts: %w", err)
+		}
+
+		for _, d := range out.StackResourceDrifts {
+			drift := ResourceDrift{
+				LogicalResourceID:  aws.StringValue(d.LogicalResourceId),
+				PhysicalResourceID: aws.StringValue(d.PhysicalResourceId),
+				ResourceType:       aws.StringValue(d.ResourceType),
+				Status:             aws.StringValue(d.StackResourceDriftStatus),
+			}
+
+			for _, p := range d.PropertyDifferences {
+				drift.Properties = append(drift.Properties, PropertyDrift{
+					Path:           aws.StringValue(p.PropertyPath),
+					DifferenceType: aws.StringValue(p.DifferenceType),
+					Expected:       aws.StringValue(p.ExpectedValue),
+					Actual:         aws.StringValue(p.ActualValue),
+				})
+			}
+
+			drifts = append(drifts, drift)
+		}
+
+		if out.NextToken == nil {
+			return drifts, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
+
+// refuseDrifted returns a *StackDriftError if RefuseDrifted is set and the stack has drifted.
+func (c *Cloudformation) refuseDrifted() error {
+	if !c.RefuseDrifted {
+		return nil
+	}
+
+	report, err := c.DetectDrift()
+	if err != nil {
+		return err
+	}
+
+	if report.HasDrifted() {
+		return &StackDriftError{Report: report}
+	}
+
+	return nil
+}
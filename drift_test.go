@@ -0,0 +1,155 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+type mockDriftClient struct {
+	mockDeploymentHashClient
+	driftStatus     string
+	detectionStatus string
+}
+
+func (m mockDriftClient) DetectStackDrift(*cloudformation.DetectStackDriftInput) (*cloudformation.DetectStackDriftOutput, error) {
+	return &cloudformation.DetectStackDriftOutput{StackDriftDetectionId: aws.String("detection-1")}, nil
+}
+
+func (m mockDriftClient) DescribeStackDriftDetectionStatus(
+	input *cloudformation.DescribeStackDriftDetectionStatusInput,
+) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	if aws.StringValue(input.StackDriftDetectionId) != "detection-1" {
+		return nil, errors.New("unknown detection")
+	}
+
+	return &cloudformation.DescribeStackDriftDetectionStatusOutput{
+		DetectionStatus:       aws.String(m.detectionStatus),
+		DetectionStatusReason: aws.String("resource type not supported"),
+		StackDriftStatus:      aws.String(m.driftStatus),
+	}, nil
+}
+
+func (m mockDriftClient) DescribeStackResourceDrifts(
+	input *cloudformation.DescribeStackResourceDriftsInput,
+) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	if m.driftStatus != cloudformation.StackDriftStatusDrifted {
+		return &cloudformation.DescribeStackResourceDriftsOutput{}, nil
+	}
+
+	if input.NextToken == nil {
+		return &cloudformation.DescribeStackResourceDriftsOutput{
+			NextToken: aws.String("page-2"),
+			StackResourceDrifts: []*cloudformation.StackResourceDrift{{
+				LogicalResourceId:        aws.String("Topic"),
+				PhysicalResourceId:       aws.String("arn:topic"),
+				ResourceType:             aws.String("AWS::SNS::Topic"),
+				StackResourceDriftStatus: aws.String(cloudformation.StackResourceDriftStatusModified),
+				PropertyDifferences: []*cloudformation.PropertyDifference{{
+					PropertyPath:   aws.String("/DisplayName"),
+					DifferenceType: aws.String(cloudformation.DifferenceTypeNotEqual),
+					ExpectedValue:  aws.String("alerts"),
+					ActualValue:    aws.String("changed"),
+				}},
+			}},
+		}, nil
+	}
+
+	return &cloudformation.DescribeStackResourceDriftsOutput{
+		StackResourceDrifts: []*cloudformation.StackResourceDrift{{
+			LogicalResourceId:        aws.String("Queue"),
+			ResourceType:             aws.String("AWS::SQS::Queue"),
+			StackResourceDriftStatus: aws.String(cloudformation.StackResourceDriftStatusDeleted),
+		}},
+	}, nil
+}
+
+func newMockDriftClient(driftStatus, detectionStatus string) mockDriftClient {
+	return mockDriftClient{
+		mockDeploymentHashClient: mockDeploymentHashClient{
+			status: cloudformation.StackStatusUpdateComplete,
+			tags:   new([]*cloudformation.Tag),
+		},
+		driftStatus:     driftStatus,
+		detectionStatus: detectionStatus,
+	}
+}
+
+func TestCloudformation_DetectDrift(t *testing.T) {
+	c := &Cloudformation{
+		CFClient:  newMockDriftClient(cloudformation.StackDriftStatusDrifted, cloudformation.StackDriftDetectionStatusDetectionFailed),
+		StackName: "test",
+	}
+
+	report, err := c.DetectDrift()
+	if err != nil {
+		t.Fatalf("DetectDrift() unexpected error = %v", err)
+	}
+
+	want := &DriftReport{
+		StackName:             "test",
+		DetectionID:           "detection-1",
+		Status:                cloudformation.StackDriftStatusDrifted,
+		DetectionStatusReason: "resource type not supported",
+		Resources: []ResourceDrift{
+			{
+				LogicalResourceID:  "Topic",
+				PhysicalResourceID: "arn:topic",
+				ResourceType:       "AWS::SNS::Topic",
+				Status:             cloudformation.StackResourceDriftStatusModified,
+				Properties: []PropertyDrift{
+					{Path: "/DisplayName", DifferenceType: cloudformation.DifferenceTypeNotEqual, Expected: "alerts", Actual: "changed"},
+				},
+			},
+			{LogicalResourceID: "Queue", ResourceType: "AWS::SQS::Queue", Status: cloudformation.StackResourceDriftStatusDeleted},
+		},
+	}
+
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("DetectDrift() = %+v, want %+v", report, want)
+	}
+
+	if !report.HasDrifted() || !strings.Contains(report.String(), "NOT_EQUAL /DisplayName: expected alerts, actual changed") {
+		t.Errorf("String() = %s", report)
+	}
+}
+
+func TestCloudformation_CloudFormationDeploy_refuseDrifted(t *testing.T) {
+	tests := []struct {
+		name        string
+		driftStatus string
+		wantErr     error
+	}{
+		{name: "Test in sync stack is deployed", driftStatus: cloudformation.StackDriftStatusInSync, wantErr: errChangeSetCreated},
+		{name: "Test drifted stack is refused", driftStatus: cloudformation.StackDriftStatusDrifted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cloudformation{
+				CFClient:      newMockDriftClient(tt.driftStatus, cloudformation.StackDriftDetectionStatusDetectionComplete),
+				StackName:     "test",
+				RefuseDrifted: true,
+			}
+
+			err := c.CloudFormationDeploy(hashTemplate, false)
+
+			var driftErr *StackDriftError
+			if tt.wantErr == nil {
+				if !errors.As(err, &driftErr) || driftErr.Error() != "stack test has drifted: Topic (MODIFIED), Queue (DELETED)" {
+					t.Errorf("CloudFormationDeploy() error = %v, want *StackDriftError", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CloudFormationDeploy() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
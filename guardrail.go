@@ -0,0 +1,298 @@
+package godeploycfn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"gopkg.in/yaml.v3"
+)
+
+// GuardrailEffect decides what happens if a GuardrailRule is violated.
+type GuardrailEffect string
+
+const (
+	// GuardrailDeny deletes the ChangeSet and fails the deployment.
+	GuardrailDeny GuardrailEffect = "deny"
+	// GuardrailWarn only logs the violation.
+	GuardrailWarn GuardrailEffect = "warn"
+)
+
+// GuardrailReplace matches modifications which replace a resource, or might replace it.
+// The other actions are the ChangeSet actions like Add, Modify and Remove.
+const GuardrailReplace = "Replace"
+
+// GuardrailPolicy is a set of rules every ChangeSet is checked against before it gets executed.
+// A policy may be shared by parallel deployments, but its rules must not be changed after the first Evaluate.
+type GuardrailPolicy struct {
+	Rules []GuardrailRule `json:"rules" yaml:"rules"`
+
+	compileOnce sync.Once
+	compiled    []compiledGuardrailRule
+	compileErr  error
+}
+
+// GuardrailRule matches resource changes by resource type, logical ID and action. Empty criteria match
+// every change. Without MaxCount every matching change violates the rule, otherwise the rule is violated
+// if more than MaxCount changes match.
+type GuardrailRule struct {
+	Name string `json:"name" yaml:"name"`
+	// Effect defaults to GuardrailDeny.
+	Effect GuardrailEffect `json:"effect,omitempty" yaml:"effect,omitempty"`
+	// ResourceTypes are patterns like "AWS::DynamoDB::Table" or "AWS::RDS::*".
+	ResourceTypes []string `json:"resourceTypes,omitempty" yaml:"resourceTypes,omitempty"`
+	// LogicalIDPattern is a regular expression matched against the logical IDs.
+	LogicalIDPattern string `json:"logicalIdPattern,omitempty" yaml:"logicalIdPattern,omitempty"`
+	// Actions are ChangeSet actions (Add, Modify, Remove, Import, Dynamic) or GuardrailReplace.
+	Actions  []string `json:"actions,omitempty" yaml:"actions,omitempty"`
+	MaxCount *int     `json:"maxCount,omitempty" yaml:"maxCount,omitempty"`
+}
+
+// compiledGuardrailRule is a validated rule with its compiled logical ID pattern.
+type compiledGuardrailRule struct {
+	*GuardrailRule
+	logicalID *regexp.Regexp
+}
+
+// GuardrailViolation is a violated rule and the changes which violate it.
+type GuardrailViolation struct {
+	Rule    string                  `json:"rule"`
+	Effect  GuardrailEffect         `json:"effect"`
+	Changes []ResourceChangeSummary `json:"changes"`
+}
+
+func (v GuardrailViolation) String() string {
+	changes := make([]string, 0, len(v.Changes))
+	for _, c := range v.Changes {
+		action := c.Action
+		if c.Replacement == cloudformation.ReplacementTrue || c.Replacement == cloudformation.ReplacementConditional {
+			action = fmt.Sprintf("%s (replacement %s)", action, c.Replacement)
+		}
+
+		changes = append(changes, fmt.Sprintf("%s %s %s", action, c.ResourceType, c.LogicalResourceID))
+	}
+
+	return fmt.Sprintf("rule %q: %s", v.Rule, strings.Join(changes, ", "))
+}
+
+// GuardrailViolationError is returned by CloudFormationDeploy if a ChangeSet violates deny rules of the Guardrails.
+type GuardrailViolationError struct {
+	Violations []GuardrailViolation
+}
+
+func (e *GuardrailViolationError) Error() string {
+	violations := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		violations = append(violations, v.String())
+	}
+
+	return "change set violates guardrails: " + strings.Join(violations, "; ")
+}
+
+// LoadGuardrailPolicy reads a GuardrailPolicy from a JSON or YAML file.
+func LoadGuardrailPolicy(filename string) (*GuardrailPolicy, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening guardrail policy: %w", err)
+	}
+	defer f.Close()
+
+	return ParseGuardrailPolicy(f)
+}
+
+// ParseGuardrailPolicy parses and validates a GuardrailPolicy in JSON or YAML.
+func ParseGuardrailPolicy(r io.Reader) (*GuardrailPolicy, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	p := &GuardrailPolicy{}
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing guardrail policy: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Validate checks the rules of the policy.
+func (p *GuardrailPolicy) Validate() error {
+	_, err := p.compileRules()
+
+	return err
+}
+
+// compileRules validates the rules and compiles their logical ID patterns.
+func (p *GuardrailPolicy) compileRules() ([]compiledGuardrailRule, error) {
+	compiled := make([]compiledGuardrailRule, 0, len(p.Rules))
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if r.Name == "" {
+			return nil, fmt.Errorf("guardrail rule #%d has no name", i+1)
+		}
+
+		switch r.Effect {
+		case "", GuardrailDeny, GuardrailWarn:
+		default:
+			return nil, fmt.Errorf("guardrail rule %q has unknown effect %q", r.Name, r.Effect)
+		}
+
+		for _, a := range r.Actions {
+			if a != GuardrailReplace && !contains(cloudformation.ChangeAction_Values(), a) {
+				return nil, fmt.Errorf("guardrail rule %q has unknown action %q", r.Name, a)
+			}
+		}
+
+		for _, t := range r.ResourceTypes {
+			if _, err := path.Match(t, ""); err != nil {
+				return nil, fmt.Errorf("guardrail rule %q has invalid resource type pattern %q: %w", r.Name, t, err)
+			}
+		}
+
+		if r.MaxCount != nil && *r.MaxCount < 0 {
+			return nil, fmt.Errorf("guardrail rule %q has a negative maxCount", r.Name)
+		}
+
+		rule := compiledGuardrailRule{GuardrailRule: r}
+
+		if r.LogicalIDPattern != "" {
+			re, err := regexp.Compile(r.LogicalIDPattern)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail rule %q has invalid logical ID pattern: %w", r.Name, err)
+			}
+
+			rule.logicalID = re
+		}
+
+		compiled = append(compiled, rule)
+	}
+
+	return compiled, nil
+}
+
+// Evaluate returns the violations of all rules, in the order of the rules. The rules are validated and
+// compiled on the first call only.
+func (p *GuardrailPolicy) Evaluate(changeSet *cloudformation.DescribeChangeSetOutput) ([]GuardrailViolation, error) {
+	p.compileOnce.Do(func() {
+		p.compiled, p.compileErr = p.compileRules()
+	})
+
+	if p.compileErr != nil {
+		return nil, p.compileErr
+	}
+
+	changes := SummarizeChangeSet(changeSet).Changes
+
+	var violations []GuardrailViolation
+
+	for _, r := range p.compiled {
+		var matching []ResourceChangeSummary
+
+		for _, c := range changes {
+			if r.matches(c) {
+				matching = append(matching, c)
+			}
+		}
+
+		if len(matching) == 0 || (r.MaxCount != nil && len(matching) <= *r.MaxCount) {
+			continue
+		}
+
+		effect := r.Effect
+		if effect == "" {
+			effect = GuardrailDeny
+		}
+
+		violations = append(violations, GuardrailViolation{Rule: r.Name, Effect: effect, Changes: matching})
+	}
+
+	return violations, nil
+}
+
+func (r compiledGuardrailRule) matches(c ResourceChangeSummary) bool {
+	if r.logicalID != nil && !r.logicalID.MatchString(c.LogicalResourceID) {
+		return false
+	}
+
+	if len(r.ResourceTypes) > 0 {
+		matched := false
+
+		for _, t := range r.ResourceTypes {
+			if ok, _ := path.Match(t, c.ResourceType); ok {
+				matched = true
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(r.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range r.Actions {
+		if a == c.Action {
+			return true
+		}
+
+		if a == GuardrailReplace && (c.Replacement == cloudformation.ReplacementTrue ||
+			c.Replacement == cloudformation.ReplacementConditional) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enforceGuardrails logs warnings and returns a *GuardrailViolationError if deny rules are violated.
+func (c *Cloudformation) enforceGuardrails(changeSet *cloudformation.DescribeChangeSetOutput) error {
+	if c.Guardrails == nil {
+		return nil
+	}
+
+	violations, err := c.Guardrails.Evaluate(changeSet)
+	if err != nil {
+		return err
+	}
+
+	var denied []GuardrailViolation
+
+	for _, v := range violations {
+		if v.Effect == GuardrailWarn {
+			c.logger().Warnf("ChangeSet '%s' violates guardrail %s", aws.StringValue(changeSet.ChangeSetName), v)
+
+			continue
+		}
+
+		denied = append(denied, v)
+	}
+
+	if len(denied) > 0 {
+		return &GuardrailViolationError{Violations: denied}
+	}
+
+	return nil
+}
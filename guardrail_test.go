@@ -0,0 +1,202 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const guardrailPolicyYAML = `rules:
+  - name: keep-tables
+    resourceTypes: ["AWS::DynamoDB::*"]
+    actions: [Remove, Replace]
+  - name: few-deletions
+    actions: [Remove]
+    maxCount: 1
+  - name: prod-resources
+    effect: warn
+    logicalIdPattern: ^Prod
+`
+
+func typedChange(action, logicalID, resourceType, replacement string) *cloudformation.Change {
+	c := resourceChange(action, logicalID, replacement)
+	c.ResourceChange.ResourceType = aws.String(resourceType)
+
+	return c
+}
+
+func TestParseGuardrailPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{name: "Test YAML", in: guardrailPolicyYAML},
+		{name: "Test JSON", in: `{"rules": [{"name": "no-removals", "actions": ["Remove"], "maxCount": 0}]}`},
+		{name: "Test empty policy", in: ""},
+		{name: "Test unknown field", in: `{"rules": [{"name": "a", "action": ["Remove"]}]}`, wantErr: "field action not found"},
+		{name: "Test missing name", in: `{"rules": [{"actions": ["Remove"]}]}`, wantErr: "has no name"},
+		{name: "Test unknown action", in: `{"rules": [{"name": "a", "actions": ["Delete"]}]}`, wantErr: "unknown action"},
+		{name: "Test unknown effect", in: `{"rules": [{"name": "a", "effect": "block"}]}`, wantErr: "unknown effect"},
+		{name: "Test invalid pattern", in: `{"rules": [{"name": "a", "logicalIdPattern": "("}]}`, wantErr: "invalid logical ID pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGuardrailPolicy(strings.NewReader(tt.in))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ParseGuardrailPolicy() unexpected error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ParseGuardrailPolicy() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGuardrailPolicy_Evaluate(t *testing.T) {
+	policy, err := ParseGuardrailPolicy(strings.NewReader(guardrailPolicyYAML))
+	if err != nil {
+		t.Fatalf("ParseGuardrailPolicy() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		changes []*cloudformation.Change
+		want    map[string][]string
+	}{
+		{
+			name: "Test allowed changes",
+			changes: []*cloudformation.Change{
+				typedChange(cloudformation.ChangeActionModify, "Table", "AWS::DynamoDB::Table", cloudformation.ReplacementFalse),
+				typedChange(cloudformation.ChangeActionRemove, "Topic", "AWS::SNS::Topic", ""),
+			},
+			want: map[string][]string{},
+		},
+		{
+			name: "Test replaced table",
+			changes: []*cloudformation.Change{
+				typedChange(cloudformation.ChangeActionModify, "Table", "AWS::DynamoDB::Table", cloudformation.ReplacementConditional),
+			},
+			want: map[string][]string{"keep-tables": {"Table"}},
+		},
+		{
+			name: "Test too many deletions",
+			changes: []*cloudformation.Change{
+				typedChange(cloudformation.ChangeActionRemove, "Topic", "AWS::SNS::Topic", ""),
+				typedChange(cloudformation.ChangeActionRemove, "Table", "AWS::DynamoDB::Table", ""),
+				typedChange(cloudformation.ChangeActionAdd, "ProdQueue", "AWS::SQS::Queue", ""),
+			},
+			want: map[string][]string{
+				"keep-tables":    {"Table"},
+				"few-deletions":  {"Topic", "Table"},
+				"prod-resources": {"ProdQueue"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Evaluate(&cloudformation.DescribeChangeSetOutput{Changes: tt.changes})
+			if err != nil {
+				t.Fatalf("Evaluate() unexpected error = %v", err)
+			}
+
+			got := map[string][]string{}
+			for _, v := range violations {
+				for _, c := range v.Changes {
+					got[v.Rule] = append(got[v.Rule], c.LogicalResourceID)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudformation_approveChangeSet_guardrails(t *testing.T) {
+	policy, err := ParseGuardrailPolicy(strings.NewReader(guardrailPolicyYAML))
+	if err != nil {
+		t.Fatalf("ParseGuardrailPolicy() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		change      *cloudformation.Change
+		wantErr     bool
+		wantDeleted int
+	}{
+		{
+			name:   "Test warnings don't stop the deployment",
+			change: typedChange(cloudformation.ChangeActionModify, "ProdTopic", "AWS::SNS::Topic", cloudformation.ReplacementFalse),
+		},
+		{
+			name:        "Test denied change set gets deleted",
+			change:      typedChange(cloudformation.ChangeActionRemove, "Table", "AWS::DynamoDB::Table", ""),
+			wantErr:     true,
+			wantDeleted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := &[]string{}
+			c := &Cloudformation{
+				CFClient: mockChangeSetClient{
+					pages: []*cloudformation.DescribeChangeSetOutput{
+						{ChangeSetName: aws.String("cs"), Changes: []*cloudformation.Change{tt.change}},
+					},
+					deleted: deleted,
+				},
+				StackName:  "test",
+				Guardrails: policy,
+			}
+
+			err := c.approveChangeSet(&cloudformation.DescribeChangeSetInput{ChangeSetName: aws.String("cs")}, cloudformation.ChangeSetTypeUpdate)
+
+			var violation *GuardrailViolationError
+			if tt.wantErr != errors.As(err, &violation) {
+				t.Fatalf("approveChangeSet() error = %v, want violation %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && err.Error() != `change set violates guardrails: rule "keep-tables": Remove AWS::DynamoDB::Table Table` {
+				t.Errorf("approveChangeSet() error = %v", err)
+			}
+
+			if len(*deleted) != tt.wantDeleted {
+				t.Errorf("deleted change sets = %v, want %d", *deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestGuardrailPolicy_Evaluate_parallel(t *testing.T) {
+	policy := &GuardrailPolicy{Rules: []GuardrailRule{{Name: "prod-resources", LogicalIDPattern: "^Prod"}}}
+	changeSet := &cloudformation.DescribeChangeSetOutput{Changes: []*cloudformation.Change{
+		typedChange(cloudformation.ChangeActionAdd, "ProdQueue", "AWS::SQS::Queue", ""),
+	}}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if violations, err := policy.Evaluate(changeSet); err != nil || len(violations) != 1 {
+				t.Errorf("Evaluate() = %v, %v, want one violation", violations, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
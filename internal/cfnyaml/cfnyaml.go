@@ -0,0 +1,163 @@
+// Package cfnyaml parses CloudFormation templates written in JSON or YAML, including the short-form
+// intrinsic function tags like !Ref or !GetAtt.
+package cfnyaml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shortForms maps the YAML short-form tags to the names of the intrinsic functions they stand for.
+var shortForms = map[string]string{
+	"!Ref":          "Ref",
+	"!Condition":    "Condition",
+	"!Base64":       "Fn::Base64",
+	"!Cidr":         "Fn::Cidr",
+	"!FindInMap":    "Fn::FindInMap",
+	"!GetAtt":       "Fn::GetAtt",
+	"!GetAZs":       "Fn::GetAZs",
+	"!ImportValue":  "Fn::ImportValue",
+	"!Join":         "Fn::Join",
+	"!Select":       "Fn::Select",
+	"!Split":        "Fn::Split",
+	"!Sub":          "Fn::Sub",
+	"!Transform":    "Fn::Transform",
+	"!And":          "Fn::And",
+	"!Equals":       "Fn::Equals",
+	"!If":           "Fn::If",
+	"!Not":          "Fn::Not",
+	"!Or":           "Fn::Or",
+	"!Length":       "Fn::Length",
+	"!ToJsonString": "Fn::ToJsonString",
+}
+
+// Parse parses a JSON or YAML template and returns its top level mapping node.
+func Parse(body []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("error parsing template: template is not a mapping")
+	}
+
+	return doc.Content[0], nil
+}
+
+// FunctionName returns the long-form name of the intrinsic function of a short-form tag like !Ref,
+// or an empty string if the tag isn't one.
+func FunctionName(tag string) string {
+	return shortForms[tag]
+}
+
+// Intrinsic returns the name and argument of the intrinsic function n stands for, either written in short
+// form (`!Ref Foo`) or long form (`{"Ref": "Foo"}`). ok is false if n isn't an intrinsic function.
+func Intrinsic(n *yaml.Node) (name string, arg *yaml.Node, ok bool) {
+	if fn := FunctionName(n.Tag); fn != "" {
+		return fn, n, true
+	}
+
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		key := n.Content[0].Value
+		if key == "Ref" || key == "Condition" || strings.HasPrefix(key, "Fn::") {
+			return key, n.Content[1], true
+		}
+	}
+
+	return "", nil, false
+}
+
+// Lookup returns the value of key in the mapping node n, or nil.
+func Lookup(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// Normalize converts a node into plain Go values (map[string]interface{}, []interface{}, strings, numbers,
+// booleans and nil). Short-form tags are expanded into their long form, so `!GetAtt A.B` and
+// `{"Fn::GetAtt": ["A", "B"]}` result in the same value.
+func Normalize(n *yaml.Node) (interface{}, error) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		return Normalize(n.Content[0])
+	}
+
+	if n.Kind == yaml.AliasNode {
+		return Normalize(n.Alias)
+	}
+
+	if fn := FunctionName(n.Tag); fn != "" {
+		untagged := *n
+		untagged.Tag = ""
+
+		if n.Kind == yaml.ScalarNode {
+			untagged.Tag = "!!str"
+		}
+
+		arg, err := Normalize(&untagged)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, isString := arg.(string); isString && fn == "Fn::GetAtt" {
+			resource, attribute, _ := strings.Cut(s, ".")
+			arg = []interface{}{resource, attribute}
+		}
+
+		return map[string]interface{}{fn: arg}, nil
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(n.Content)/2)
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "<<" && n.Content[i].Tag == "!!merge" {
+				return nil, fmt.Errorf("line %d: YAML merge keys are not supported", n.Content[i].Line)
+			}
+
+			v, err := Normalize(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+
+			m[n.Content[i].Value] = v
+		}
+
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, 0, len(n.Content))
+
+		for _, c := range n.Content {
+			v, err := Normalize(c)
+			if err != nil {
+				return nil, err
+			}
+
+			s = append(s, v)
+		}
+
+		return s, nil
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n.Line, err)
+		}
+
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("line %d: unexpected YAML node", n.Line)
+}
@@ -0,0 +1,54 @@
+package cfnyaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	short := `
+Resources:
+  Alarm:
+    Properties:
+      Topic: !Ref Topic
+      Name: !GetAtt Topic.TopicName
+      Description: !Sub "${AWS::StackName}"
+      Count: 3
+`
+	long := `{
+  "Resources": {
+    "Alarm": {
+      "Properties": {
+        "Count": 3,
+        "Description": {"Fn::Sub": "${AWS::StackName}"},
+        "Name": {"Fn::GetAtt": ["Topic", "TopicName"]},
+        "Topic": {"Ref": "Topic"}
+      }
+    }
+  }
+}`
+
+	shortNode, err := Parse([]byte(short))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	longNode, err := Parse([]byte(long))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Normalize(shortNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Normalize(longNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Normalize() short form = %v, long form = %v", a, b)
+	}
+}
@@ -0,0 +1,227 @@
+package godeploycfn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// DeploymentOutcome is the result of a deployment recorded in a Journal.
+type DeploymentOutcome string
+
+const (
+	// DeploymentSucceeded means the ChangeSet was executed successfully.
+	DeploymentSucceeded DeploymentOutcome = "SUCCEEDED"
+	// DeploymentUnchanged means there was nothing to deploy.
+	DeploymentUnchanged DeploymentOutcome = "UNCHANGED"
+	// DeploymentRejected means the ChangeSet was rejected by the Approver.
+	DeploymentRejected DeploymentOutcome = "REJECTED"
+	// DeploymentDeferred means the ChangeSet was deferred by the Approver.
+	DeploymentDeferred DeploymentOutcome = "DEFERRED"
+	// DeploymentFailed means the deployment returned an error.
+	DeploymentFailed DeploymentOutcome = "FAILED"
+)
+
+// redactedValue replaces the values of NoEcho parameters in DeploymentRecords.
+const redactedValue = "****"
+
+// DeploymentRecord describes a single deployment attempt.
+type DeploymentRecord struct {
+	StackName    string `json:"stackName"`
+	TemplateHash string `json:"templateHash,omitempty"`
+	// TemplateBody and NamedIAM allow redeploying the record, see Redeploy.
+	TemplateBody string `json:"templateBody,omitempty"`
+	NamedIAM     bool   `json:"namedIAM,omitempty"`
+	// Parameters are the parameters of the deployment, values of NoEcho parameters are redacted.
+	Parameters  []*cloudformation.Parameter `json:"parameters,omitempty"`
+	ChangeSetID string                      `json:"changeSetId,omitempty"`
+	Changes     []ResourceChangeSummary     `json:"changes,omitempty"`
+	Outcome     DeploymentOutcome           `json:"outcome"`
+	// Error is the error message of a failed deployment.
+	Error     string            `json:"error,omitempty"`
+	StartedAt time.Time         `json:"startedAt"`
+	Duration  time.Duration     `json:"duration"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Journal stores the history of deployments.
+type Journal interface {
+	// Record adds the record to the journal.
+	Record(record *DeploymentRecord) error
+	// History returns the records of the stack, oldest first.
+	History(stackName string) ([]*DeploymentRecord, error)
+}
+
+// FileJournal is a Journal appending the records to a file with one JSON object per line.
+type FileJournal struct {
+	Filename string
+
+	mu sync.Mutex
+}
+
+// NewFileJournal returns a FileJournal writing to filename.
+func NewFileJournal(filename string) *FileJournal {
+	return &FileJournal{Filename: filename}
+}
+
+// Record appends the record to the file, which is created if it doesn't exist.
+func (j *FileJournal) Record(record *DeploymentRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding deployment record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening journal: %w", err)
+	}
+
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	return nil
+}
+
+// History reads the records of the stack from the file. A missing file is an empty history.
+func (j *FileJournal) History(stackName string) ([]*DeploymentRecord, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var records []*DeploymentRecord
+
+	dec := json.NewDecoder(f)
+
+	for {
+		record := &DeploymentRecord{}
+		if err = dec.Decode(record); errors.Is(err, io.EOF) {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading journal %s: %w", j.Filename, err)
+		}
+
+		if record.StackName == stackName {
+			records = append(records, record)
+		}
+	}
+}
+
+// newDeploymentRecord starts the record of a deployment. Errors are ignored, as the deployment reports them.
+func (c *Cloudformation) newDeploymentRecord(templateBody string, namedIAM bool) *DeploymentRecord {
+	hash, _ := c.DeploymentHash(templateBody, namedIAM)
+
+	return &DeploymentRecord{
+		StackName:    c.StackName,
+		TemplateHash: hash,
+		TemplateBody: templateBody,
+		NamedIAM:     namedIAM,
+		Parameters:   redactParameters(templateBody, c.Parameters),
+		StartedAt:    time.Now().UTC(),
+		Metadata:     c.JournalMetadata,
+	}
+}
+
+// addChangeSetToRecord adds the ID and changes of the ChangeSet to the record if it isn't nil.
+func (c *Cloudformation) addChangeSetToRecord(record *DeploymentRecord, cs *changeSet) error {
+	if record == nil {
+		return nil
+	}
+
+	dcso, err := c.describeChangeSet(cs.describeInput)
+	if err != nil {
+		return err
+	}
+
+	summary := SummarizeChangeSet(dcso)
+	record.ChangeSetID = summary.ChangeSetID
+	record.Changes = summary.Changes
+
+	return nil
+}
+
+// recordDeployment completes the record with the result of the deployment and adds it to the Journal.
+// It returns the error of the deployment, or the error of the Journal if the deployment succeeded.
+func (c *Cloudformation) recordDeployment(record *DeploymentRecord, errDeploy error) error {
+	record.Duration = time.Since(record.StartedAt)
+
+	switch {
+	case errDeploy == nil && record.ChangeSetID == "":
+		record.Outcome = DeploymentUnchanged
+	case errDeploy == nil:
+		record.Outcome = DeploymentSucceeded
+	case errors.Is(errDeploy, ErrChangeSetRejected):
+		record.Outcome = DeploymentRejected
+	case errors.Is(errDeploy, ErrChangeSetDeferred):
+		record.Outcome = DeploymentDeferred
+	default:
+		record.Outcome = DeploymentFailed
+		record.Error = errDeploy.Error()
+	}
+
+	if err := c.Journal.Record(record); err != nil {
+		if errDeploy != nil {
+			c.logger().Warnf("Couldn't record the deployment: %v", err)
+
+			return errDeploy
+		}
+
+		return fmt.Errorf("error recording the deployment: %w", err)
+	}
+
+	return errDeploy
+}
+
+// redactParameters returns a copy of the parameters with the values of the template's NoEcho parameters redacted.
+func redactParameters(templateBody string, parameters []*cloudformation.Parameter) []*cloudformation.Parameter {
+	noEcho := map[string]bool{}
+
+	if template, err := normalizeTemplate(templateBody); err == nil {
+		declared, _ := template["Parameters"].(map[string]interface{})
+
+		for name, p := range declared {
+			p, _ := p.(map[string]interface{})
+			noEcho[name] = p["NoEcho"] == true || p["NoEcho"] == "true"
+		}
+	}
+
+	redacted := make([]*cloudformation.Parameter, 0, len(parameters))
+
+	for _, p := range parameters {
+		p := *p
+		if noEcho[aws.StringValue(p.ParameterKey)] && p.ParameterValue != nil {
+			p.ParameterValue = aws.String(redactedValue)
+		}
+
+		redacted = append(redacted, &p)
+	}
+
+	return redacted
+}
@@ -0,0 +1,145 @@
+package godeploycfn
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const journalTemplate = `Parameters:
+  Env:
+    Type: String
+  Password:
+    Type: String
+    NoEcho: true
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+`
+
+func TestCloudformation_CloudFormationDeploy_journal(t *testing.T) {
+	tests := []struct {
+		name        string
+		decision    ApprovalDecision
+		wantOutcome DeploymentOutcome
+	}{
+		{name: "Test successful deployment", decision: ApprovalApproved, wantOutcome: DeploymentSucceeded},
+		{name: "Test rejected deployment", decision: ApprovalRejected, wantOutcome: DeploymentRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+			c := &Cloudformation{
+				CFClient:  newMockChangeSetListClient(),
+				StackName: "test",
+				Parameters: []*cloudformation.Parameter{
+					{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+					{ParameterKey: aws.String("Password"), ParameterValue: aws.String("secret")},
+				},
+				Approver: ApproverFunc(func(*cloudformation.DescribeChangeSetOutput) (ApprovalDecision, error) {
+					return tt.decision, nil
+				}),
+				Journal:         journal,
+				JournalMetadata: map[string]string{"gitSha": "abc123"},
+			}
+
+			_ = c.CloudFormationDeploy(journalTemplate, false)
+
+			if err := journal.Record(&DeploymentRecord{StackName: "other", Outcome: DeploymentSucceeded}); err != nil {
+				t.Fatalf("Record() unexpected error = %v", err)
+			}
+
+			history, err := journal.History("test")
+			if err != nil {
+				t.Fatalf("History() unexpected error = %v", err)
+			}
+
+			if len(history) != 1 {
+				t.Fatalf("History() = %d records, want 1", len(history))
+			}
+
+			record := history[0]
+			if record.Outcome != tt.wantOutcome || record.ChangeSetID == "" || len(record.Changes) != 1 || record.TemplateHash == "" {
+				t.Errorf("History() record = %+v", record)
+			}
+
+			if got := aws.StringValue(record.Parameters[0].ParameterValue); got != "prod" {
+				t.Errorf("parameter Env = %q, want prod", got)
+			}
+
+			if got := aws.StringValue(record.Parameters[1].ParameterValue); got != redactedValue {
+				t.Errorf("parameter Password = %q, want it redacted", got)
+			}
+
+			if record.Metadata["gitSha"] != "abc123" {
+				t.Errorf("metadata = %v", record.Metadata)
+			}
+		})
+	}
+}
+
+func TestFileJournal_History_missingFile(t *testing.T) {
+	journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+
+	history, err := journal.History("test")
+	if err != nil || history != nil {
+		t.Errorf("History() = %v, %v, want empty history", history, err)
+	}
+
+	if _, err = os.Stat(journal.Filename); !os.IsNotExist(err) {
+		t.Errorf("History() must not create the journal, stat error = %v", err)
+	}
+}
+
+func TestCloudformation_Apply_journal(t *testing.T) {
+	journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err := journal.Record(&DeploymentRecord{
+		StackName: "test", TemplateHash: "v1", TemplateBody: journalTemplate, Outcome: DeploymentSucceeded,
+	}); err != nil {
+		t.Fatalf("Record() unexpected error = %v", err)
+	}
+
+	c := &Cloudformation{
+		CFClient:  newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", "")),
+		StackName: "test",
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+			{ParameterKey: aws.String("Password"), ParameterValue: aws.String("secret")},
+		},
+	}
+
+	plan, err := c.Plan(journalTemplate, false)
+	if err != nil {
+		t.Fatalf("Plan() unexpected error = %v", err)
+	}
+
+	c.Journal = journal
+	if err = c.Apply(plan); err != nil {
+		t.Fatalf("Apply() unexpected error = %v", err)
+	}
+
+	history, err := journal.History("test")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("History() = %d records, %v, want 2", len(history), err)
+	}
+
+	record := history[1]
+	if record.Outcome != DeploymentSucceeded || record.ChangeSetID != plan.ChangeSetID || len(record.Changes) != 1 ||
+		record.TemplateHash != plan.TemplateHash {
+		t.Errorf("History() record = %+v", record)
+	}
+
+	if got := aws.StringValue(record.Parameters[1].ParameterValue); got != redactedValue {
+		t.Errorf("parameter Password = %q, want it redacted", got)
+	}
+
+	// the applied plan is the current version, even though it can't be redeployed itself
+	previous, err := c.PreviousDeployment()
+	if err != nil || previous.TemplateHash != "v1" {
+		t.Errorf("PreviousDeployment() = %v, %v, want v1", previous, err)
+	}
+}
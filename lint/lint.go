@@ -0,0 +1,501 @@
+// Package lint checks CloudFormation templates for common mistakes without calling AWS.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/moia-oss/go-deploy-cfn/internal/cfnyaml"
+)
+
+// Severity of a Finding.
+type Severity string
+
+const (
+	// SeverityError marks findings which make the deployment fail.
+	SeverityError Severity = "error"
+	// SeverityWarning marks findings which are most likely mistakes but don't break the deployment.
+	SeverityWarning Severity = "warning"
+)
+
+// Rule IDs of all findings reported by Lint.
+const (
+	RuleUndefinedRef        = "E001"
+	RuleUndefinedGetAtt     = "E002"
+	RuleUndefinedDependsOn  = "E003"
+	RuleCircularDependency  = "E004"
+	RuleInvalidLogicalID    = "E005"
+	RuleDuplicateExportName = "E006"
+	RuleUndefinedCondition  = "E007"
+	RuleUnusedParameter     = "W001"
+	RuleUnusedCondition     = "W002"
+)
+
+const maxLogicalIDLength = 255
+
+var (
+	logicalIDRegexp = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	subVarRegexp    = regexp.MustCompile(`\$\{([^}!][^}]*)\}`)
+)
+
+// Finding is a single problem found in a template.
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Message  string
+	Line     int
+	Column   int
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d: %s [%s] %s", f.Line, f.Column, f.Severity, f.RuleID, f.Message)
+}
+
+// HasErrors reports whether any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LintFile lints the template at path.
+func LintFile(path string) ([]Finding, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %w", err)
+	}
+
+	return Lint(body)
+}
+
+// Lint lints a JSON or YAML template. An error is only returned if the template can't be parsed,
+// all other problems are reported as findings sorted by position.
+func Lint(body []byte) ([]Finding, error) {
+	root, err := cfnyaml.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	l := newLinter(root)
+	l.checkLogicalIDs()
+	l.collectReferences()
+	l.checkDependsOn()
+	l.checkCycles()
+	l.checkUnused()
+	l.checkExportNames()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	return l.findings, nil
+}
+
+type linter struct {
+	root       *yaml.Node
+	parameters map[string]*yaml.Node
+	conditions map[string]*yaml.Node
+	resources  map[string]*yaml.Node
+	// usedParameters and usedConditions are filled by collectReferences.
+	usedParameters map[string]bool
+	usedConditions map[string]bool
+	// dependencies holds the resources each resource depends on, via references or DependsOn.
+	dependencies map[string]map[string]bool
+	findings     []Finding
+}
+
+func newLinter(root *yaml.Node) *linter {
+	return &linter{
+		root:           root,
+		parameters:     sectionKeys(root, "Parameters"),
+		conditions:     sectionKeys(root, "Conditions"),
+		resources:      sectionKeys(root, "Resources"),
+		usedParameters: map[string]bool{},
+		usedConditions: map[string]bool{},
+		dependencies:   map[string]map[string]bool{},
+	}
+}
+
+// sectionKeys returns the key nodes of the given top level section by name.
+func sectionKeys(root *yaml.Node, section string) map[string]*yaml.Node {
+	keys := map[string]*yaml.Node{}
+
+	n := cfnyaml.Lookup(root, section)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return keys
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys[n.Content[i].Value] = n.Content[i]
+	}
+
+	return keys
+}
+
+func (l *linter) report(rule string, severity Severity, n *yaml.Node, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		RuleID:   rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Line:     n.Line,
+		Column:   n.Column,
+	})
+}
+
+func (l *linter) checkLogicalIDs() {
+	for _, section := range []string{"Parameters", "Conditions", "Mappings", "Resources", "Outputs"} {
+		keys := sectionKeys(l.root, section)
+
+		for _, id := range sortedKeys(keys) {
+			if !logicalIDRegexp.MatchString(id) || len(id) > maxLogicalIDLength {
+				l.report(RuleInvalidLogicalID, SeverityError, keys[id],
+					"logical ID %q in %s must be alphanumeric and at most %d characters long", id, section, maxLogicalIDLength)
+			}
+		}
+	}
+}
+
+// collectReferences walks all sections which may contain references and records which parameters,
+// conditions and resources are used where.
+func (l *linter) collectReferences() {
+	for i := 0; i+1 < len(l.root.Content); i += 2 {
+		section, body := l.root.Content[i].Value, l.root.Content[i+1]
+
+		switch section {
+		case "Resources", "Outputs":
+			for j := 0; j+1 < len(body.Content); j += 2 {
+				owner := ""
+				if section == "Resources" {
+					owner = body.Content[j].Value
+				}
+
+				l.walkDefinition(owner, body.Content[j+1])
+			}
+		case "Conditions", "Rules", "Metadata", "Transform":
+			l.walk("", body)
+		}
+	}
+}
+
+// walkDefinition walks a resource or output, whose Condition attribute refers to a condition.
+func (l *linter) walkDefinition(owner string, n *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		switch n.Content[i].Value {
+		case "Condition":
+			l.useCondition(n.Content[i+1])
+		case "DependsOn":
+			// checked by checkDependsOn
+		default:
+			l.walk(owner, n.Content[i+1])
+		}
+	}
+}
+
+func (l *linter) walk(owner string, n *yaml.Node) {
+	if n.Kind == yaml.AliasNode {
+		l.walk(owner, n.Alias)
+
+		return
+	}
+
+	if name, arg, ok := cfnyaml.Intrinsic(n); ok && l.walkIntrinsic(owner, name, arg) {
+		return
+	}
+
+	for _, c := range n.Content {
+		l.walk(owner, c)
+	}
+}
+
+// walkIntrinsic records references made by the intrinsic function. It returns true if the argument
+// has been walked already.
+func (l *linter) walkIntrinsic(owner, name string, arg *yaml.Node) bool {
+	switch name {
+	case "Ref":
+		if arg.Kind == yaml.ScalarNode {
+			l.useRef(owner, arg, arg.Value)
+
+			return true
+		}
+	case "Condition":
+		if arg.Kind == yaml.ScalarNode {
+			l.useCondition(arg)
+
+			return true
+		}
+	case "Fn::GetAtt":
+		l.useGetAtt(owner, arg)
+
+		return true
+	case "Fn::If":
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) > 0 {
+			l.useCondition(arg.Content[0])
+
+			for _, c := range arg.Content[1:] {
+				l.walk(owner, c)
+			}
+
+			return true
+		}
+	case "Fn::Sub":
+		l.useSub(owner, arg)
+
+		return true
+	}
+
+	return false
+}
+
+func (l *linter) useRef(owner string, n *yaml.Node, target string) {
+	switch {
+	case strings.HasPrefix(target, "AWS::"):
+	case l.parameters[target] != nil:
+		l.usedParameters[target] = true
+	case l.resources[target] != nil:
+		l.addDependency(owner, target)
+	default:
+		l.report(RuleUndefinedRef, SeverityError, n, "Ref to undefined parameter or resource %q", target)
+	}
+}
+
+func (l *linter) useGetAtt(owner string, arg *yaml.Node) {
+	var target string
+
+	switch {
+	case arg.Kind == yaml.ScalarNode:
+		target, _, _ = strings.Cut(arg.Value, ".")
+	case arg.Kind == yaml.SequenceNode && len(arg.Content) > 0 && arg.Content[0].Kind == yaml.ScalarNode:
+		target = arg.Content[0].Value
+
+		for _, c := range arg.Content[1:] {
+			l.walk(owner, c)
+		}
+	default:
+		l.walk(owner, arg)
+
+		return
+	}
+
+	if l.resources[target] == nil {
+		l.report(RuleUndefinedGetAtt, SeverityError, arg, "GetAtt on undefined resource %q", target)
+
+		return
+	}
+
+	l.addDependency(owner, target)
+}
+
+func (l *linter) useSub(owner string, arg *yaml.Node) {
+	str := arg
+	locals := map[string]bool{}
+
+	if arg.Kind == yaml.SequenceNode && len(arg.Content) > 0 {
+		str = arg.Content[0]
+
+		if len(arg.Content) > 1 {
+			vars := arg.Content[1]
+			for i := 0; i+1 < len(vars.Content); i += 2 {
+				locals[vars.Content[i].Value] = true
+				l.walk(owner, vars.Content[i+1])
+			}
+		}
+	}
+
+	if str.Kind != yaml.ScalarNode {
+		l.walk(owner, str)
+
+		return
+	}
+
+	for _, m := range subVarRegexp.FindAllStringSubmatch(str.Value, -1) {
+		name := strings.TrimSpace(m[1])
+		if locals[name] {
+			continue
+		}
+
+		if resource, _, isGetAtt := strings.Cut(name, "."); isGetAtt {
+			if l.resources[resource] == nil {
+				l.report(RuleUndefinedGetAtt, SeverityError, str, "Sub references attribute of undefined resource %q", resource)
+			} else {
+				l.addDependency(owner, resource)
+			}
+
+			continue
+		}
+
+		l.useRef(owner, str, name)
+	}
+}
+
+func (l *linter) useCondition(n *yaml.Node) {
+	if n.Kind != yaml.ScalarNode {
+		return
+	}
+
+	if l.conditions[n.Value] == nil {
+		l.report(RuleUndefinedCondition, SeverityError, n, "reference to undefined condition %q", n.Value)
+
+		return
+	}
+
+	l.usedConditions[n.Value] = true
+}
+
+func (l *linter) addDependency(owner, target string) {
+	if owner == "" {
+		return
+	}
+
+	if l.dependencies[owner] == nil {
+		l.dependencies[owner] = map[string]bool{}
+	}
+
+	l.dependencies[owner][target] = true
+}
+
+func (l *linter) checkDependsOn() {
+	for _, id := range sortedKeys(l.resources) {
+		dependsOn := cfnyaml.Lookup(cfnyaml.Lookup(cfnyaml.Lookup(l.root, "Resources"), id), "DependsOn")
+		if dependsOn == nil {
+			continue
+		}
+
+		targets := []*yaml.Node{dependsOn}
+		if dependsOn.Kind == yaml.SequenceNode {
+			targets = dependsOn.Content
+		}
+
+		for _, t := range targets {
+			if l.resources[t.Value] == nil {
+				l.report(RuleUndefinedDependsOn, SeverityError, t, "DependsOn refers to undefined resource %q", t.Value)
+
+				continue
+			}
+
+			l.addDependency(id, t.Value)
+		}
+	}
+}
+
+// checkCycles reports every circular dependency once, at the resource the cycle was found from.
+func (l *linter) checkCycles() {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	state := map[string]int{}
+
+	var path []string
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = inProgress
+		path = append(path, id)
+
+		for _, dep := range sortedKeys(l.dependencies[id]) {
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case inProgress:
+				start := 0
+
+				for i, p := range path {
+					if p == dep {
+						start = i
+					}
+				}
+
+				cycle := append(append([]string{}, path[start:]...), dep)
+				l.report(RuleCircularDependency, SeverityError, l.resources[dep],
+					"circular dependency between resources: %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[id] = done
+	}
+
+	for _, id := range sortedKeys(l.resources) {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+}
+
+func (l *linter) checkUnused() {
+	for _, id := range sortedKeys(l.parameters) {
+		if !l.usedParameters[id] {
+			l.report(RuleUnusedParameter, SeverityWarning, l.parameters[id], "parameter %q is never used", id)
+		}
+	}
+
+	for _, id := range sortedKeys(l.conditions) {
+		if !l.usedConditions[id] {
+			l.report(RuleUnusedCondition, SeverityWarning, l.conditions[id], "condition %q is never used", id)
+		}
+	}
+}
+
+func (l *linter) checkExportNames() {
+	outputs := cfnyaml.Lookup(l.root, "Outputs")
+	if outputs == nil {
+		return
+	}
+
+	seen := map[string]string{}
+
+	for i := 0; i+1 < len(outputs.Content); i += 2 {
+		id := outputs.Content[i].Value
+
+		name := cfnyaml.Lookup(cfnyaml.Lookup(outputs.Content[i+1], "Export"), "Name")
+		if name == nil {
+			continue
+		}
+
+		normalized, err := cfnyaml.Normalize(name)
+		if err != nil {
+			continue
+		}
+
+		key, _ := json.Marshal(normalized)
+		if first, ok := seen[string(key)]; ok {
+			l.report(RuleDuplicateExportName, SeverityError, name, "output %q exports the same name as output %q", id, first)
+
+			continue
+		}
+
+		seen[string(key)] = id
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,157 @@
+package lint
+
+import (
+	"testing"
+)
+
+const validTemplate = `
+Parameters:
+  Env:
+    Type: String
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+  Alarm:
+    Type: AWS::CloudWatch::Alarm
+    Condition: IsProd
+    DependsOn: Topic
+    Properties:
+      AlarmActions:
+        - !Ref Topic
+      AlarmName: !Sub "${AWS::StackName}-${Topic.TopicName}-${Suffix}-${!Literal}"
+      Namespace: !Sub
+        - "${Ns}"
+        - Ns: !GetAtt Topic.TopicName
+Outputs:
+  TopicArn:
+    Value: !Ref Topic
+    Export:
+      Name: !Sub "${AWS::StackName}-TopicArn"
+`
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		rules []string
+		lines []int
+	}{
+		{
+			name:  "Test valid YAML template with undefined Sub variable",
+			body:  validTemplate,
+			rules: []string{RuleUndefinedRef},
+			lines: []int{17},
+		},
+		{
+			name: "Test undefined references in JSON",
+			body: `{
+  "Resources": {
+    "Topic": {
+      "Type": "AWS::SNS::Topic",
+      "Properties": {
+        "TopicName": {"Ref": "Missing"},
+        "DisplayName": {"Fn::GetAtt": ["Nope", "Arn"]}
+      }
+    }
+  }
+}`,
+			rules: []string{RuleUndefinedRef, RuleUndefinedGetAtt},
+			lines: []int{6, 7},
+		},
+		{
+			name: "Test unused parameter and condition",
+			body: `
+Parameters:
+  Unused:
+    Type: String
+Conditions:
+  Never: !Equals [a, b]
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+`,
+			rules: []string{RuleUnusedParameter, RuleUnusedCondition},
+			lines: []int{3, 6},
+		},
+		{
+			name: "Test DependsOn, undefined condition and invalid logical ID",
+			body: `
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+    Condition: Missing
+    DependsOn: [Queue]
+  My-Queue:
+    Type: AWS::SQS::Queue
+`,
+			rules: []string{RuleUndefinedCondition, RuleUndefinedDependsOn, RuleInvalidLogicalID},
+			lines: []int{5, 6, 7},
+		},
+		{
+			name: "Test circular dependency",
+			body: `
+Resources:
+  A:
+    Type: AWS::SNS::Topic
+    Properties:
+      TopicName: !GetAtt B.QueueName
+  B:
+    Type: AWS::SQS::Queue
+    DependsOn: C
+  C:
+    Type: AWS::SQS::Queue
+    Properties:
+      QueueName: !Sub "${A}"
+`,
+			rules: []string{RuleCircularDependency},
+			lines: []int{3},
+		},
+		{
+			name: "Test duplicate export names",
+			body: `
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+Outputs:
+  One:
+    Value: !Ref Topic
+    Export:
+      Name: !Sub "${AWS::StackName}-Topic"
+  Two:
+    Value: !Ref Topic
+    Export:
+      Name:
+        Fn::Sub: "${AWS::StackName}-Topic"
+`,
+			rules: []string{RuleDuplicateExportName},
+			lines: []int{14},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := Lint([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("Lint() unexpected error = %v", err)
+			}
+
+			if len(findings) != len(tt.rules) {
+				t.Fatalf("Lint() = %v, want rules %v", findings, tt.rules)
+			}
+
+			for i, f := range findings {
+				if f.RuleID != tt.rules[i] || f.Line != tt.lines[i] {
+					t.Errorf("finding %d = %s, want rule %s in line %d", i, f, tt.rules[i], tt.lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLint_invalidTemplate(t *testing.T) {
+	if _, err := Lint([]byte("Resources: [")); err == nil {
+		t.Error("Lint() expected an error for unparseable templates")
+	}
+}
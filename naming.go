@@ -0,0 +1,164 @@
+package godeploycfn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxStackNameLength = 128
+	maxLogicalIDLength = 255
+	nameHashLength     = 8
+)
+
+var (
+	stackNameRegexp = regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]*$`)
+	logicalIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+)
+
+// NameCollisionError is returned by a NameRegistry if two different inputs result in the same name.
+type NameCollisionError struct {
+	Name   string
+	First  string
+	Second string
+}
+
+func (e *NameCollisionError) Error() string {
+	return fmt.Sprintf("%q and %q both result in the name %q", e.First, e.Second, e.Name)
+}
+
+// nameHash is a short, stable hash of the unmodified input.
+func nameHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// ValidateStackName checks whether name is a valid stack name.
+func ValidateStackName(name string) error {
+	if len(name) > maxStackNameLength || !stackNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid stack name %q: must start with a letter, contain only letters, digits and "+
+			"hyphens and be at most %d characters long", name, maxStackNameLength)
+	}
+
+	return nil
+}
+
+// SafeStackName creates a valid stack name from s. Letters, digits and hyphens are kept as they are, all other
+// characters are replaced by hyphens. If s had to be changed or shortened, a short hash of s is appended, so
+// different inputs never result in the same name.
+func SafeStackName(s string) string {
+	var sb strings.Builder
+
+	for _, r := range s {
+		if r < 128 && (r == '-' || isAlphanumeric(byte(r))) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('-')
+		}
+	}
+
+	name := sb.String()
+	if name == "" || !isLetter(name[0]) {
+		name = "s" + name
+	}
+
+	if name == s && len(name) <= maxStackNameLength {
+		return name
+	}
+
+	hash := nameHash(s)
+	name = trimStackName(strings.TrimRight(name, "-"), maxStackNameLength-nameHashLength-1)
+
+	return strings.TrimRight(name, "-") + "-" + hash
+}
+
+// SafeLogicalName creates a valid logical ID from s by removing all characters but letters and digits.
+// If s had to be changed or shortened, a short hash of s is appended, so different inputs never result in
+// the same logical ID.
+func SafeLogicalName(s string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if isAlphanumeric(s[i]) {
+			sb.WriteByte(s[i])
+		}
+	}
+
+	name := sb.String()
+	if name == s && name != "" && len(name) <= maxLogicalIDLength {
+		return name
+	}
+
+	return trimStackName(name, maxLogicalIDLength-nameHashLength) + nameHash(s)
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isAlphanumeric(b byte) bool {
+	return isLetter(b) || (b >= '0' && b <= '9')
+}
+
+// NameRegistry hands out stack names and logical IDs for a batch of inputs and detects collisions,
+// e.g. of names generated by CreateStackName or CreateLogicalName.
+type NameRegistry struct {
+	// StackNameFunc and LogicalNameFunc default to SafeStackName and SafeLogicalName.
+	StackNameFunc   func(string) string
+	LogicalNameFunc func(string) string
+
+	stackNames   map[string]string
+	logicalNames map[string]string
+}
+
+// StackName returns the stack name for s, or a *NameCollisionError if a different input already resulted in it.
+func (r *NameRegistry) StackName(s string) (string, error) {
+	fn := r.StackNameFunc
+	if fn == nil {
+		fn = SafeStackName
+	}
+
+	if r.stackNames == nil {
+		r.stackNames = map[string]string{}
+	}
+
+	name := fn(s)
+	if err := ValidateStackName(name); err != nil {
+		return "", err
+	}
+
+	return register(r.stackNames, name, s)
+}
+
+// LogicalName returns the logical ID for s, or a *NameCollisionError if a different input already resulted in it.
+func (r *NameRegistry) LogicalName(s string) (string, error) {
+	fn := r.LogicalNameFunc
+	if fn == nil {
+		fn = SafeLogicalName
+	}
+
+	if r.logicalNames == nil {
+		r.logicalNames = map[string]string{}
+	}
+
+	name := fn(s)
+	if len(name) > maxLogicalIDLength || !logicalIDRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid logical ID %q generated from %q", name, s)
+	}
+
+	return register(r.logicalNames, name, s)
+}
+
+func register(names map[string]string, name, s string) (string, error) {
+	if first, ok := names[name]; ok && first != s {
+		return "", &NameCollisionError{Name: name, First: first, Second: s}
+	}
+
+	names[name] = s
+
+	return name, nil
+}
@@ -0,0 +1,103 @@
+package godeploycfn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSafeStackName(t *testing.T) {
+	long := strings.Repeat("a", 200)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "Test valid name is kept", in: "my-alarm", want: "my-alarm"},
+		{name: "Test case is kept", in: "My-Alarm", want: "My-Alarm"},
+		{name: "Test replaced characters add a hash", in: "my_alarm", want: "my-alarm-" + nameHash("my_alarm")},
+		{name: "Test leading digit adds a prefix", in: "1alarm", want: "s1alarm-" + nameHash("1alarm")},
+		{name: "Test long names are shortened", in: long, want: long[:119] + "-" + nameHash(long)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SafeStackName(tt.in)
+			if got != tt.want {
+				t.Errorf("SafeStackName() = %v, want %v", got, tt.want)
+			}
+
+			if err := ValidateStackName(got); err != nil {
+				t.Errorf("SafeStackName() result is invalid: %v", err)
+			}
+		})
+	}
+
+	for _, pair := range [][2]string{{"my-alarm", "my_alarm"}, {"a.b", "a-b"}, {long + "x", long + "y"}} {
+		if SafeStackName(pair[0]) == SafeStackName(pair[1]) {
+			t.Errorf("SafeStackName(%q) and SafeStackName(%q) collide", pair[0], pair[1])
+		}
+	}
+}
+
+func TestSafeLogicalName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "Test valid name is kept", in: "MyAlarm", want: "MyAlarm"},
+		{name: "Test removed characters add a hash", in: "my-alarm", want: "myalarm" + nameHash("my-alarm")},
+		{name: "Test non ASCII characters are removed", in: "Größe", want: "Gre" + nameHash("Größe")},
+		{name: "Test empty name", in: "", want: nameHash("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeLogicalName(tt.in); got != tt.want {
+				t.Errorf("SafeLogicalName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if SafeLogicalName("my-alarm") == SafeLogicalName("my_alarm") {
+		t.Error("SafeLogicalName() collides for my-alarm and my_alarm")
+	}
+}
+
+func TestNameRegistry(t *testing.T) {
+	r := &NameRegistry{StackNameFunc: CreateStackName, LogicalNameFunc: CreateLogicalName}
+
+	if _, err := r.StackName("a.b"); err != nil {
+		t.Fatalf("StackName() unexpected error = %v", err)
+	}
+
+	if _, err := r.StackName("a.b"); err != nil {
+		t.Errorf("StackName() must accept the same input twice, got %v", err)
+	}
+
+	var collision *NameCollisionError
+	if _, err := r.StackName("a-b"); !errors.As(err, &collision) || collision.Name != "a-b" {
+		t.Errorf("StackName() error = %v, want collision for a-b", err)
+	}
+
+	if _, err := r.LogicalName("my-alarm"); err != nil {
+		t.Fatalf("LogicalName() unexpected error = %v", err)
+	}
+
+	if _, err := r.LogicalName("my_alarm"); !errors.As(err, &collision) {
+		t.Errorf("LogicalName() error = %v, want collision", err)
+	}
+
+	if _, err := r.LogicalName("a:b"); err == nil {
+		t.Error("LogicalName() expected an error for an invalid generated logical ID")
+	}
+
+	safe := &NameRegistry{}
+	for _, s := range []string{"my-alarm", "my_alarm", "a.b", "a-b"} {
+		if _, err := safe.StackName(s); err != nil {
+			t.Errorf("StackName(%q) unexpected error = %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package godeploycfn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// nestedStackType is the resource type of nested stacks.
+const nestedStackType = "AWS::CloudFormation::Stack"
+
+// ResourceFailure is the failure of the resource which caused a stack operation to fail.
+type ResourceFailure struct {
+	// Path lists the logical IDs of the nested stacks containing the resource, starting below the root stack.
+	Path              []string
+	StackName         string
+	LogicalResourceID string
+	ResourceType      string
+	Status            string
+	Reason            string
+}
+
+// String returns the path, type, status and reason of the failure.
+func (f *ResourceFailure) String() string {
+	id := strings.Join(append(append([]string{}, f.Path...), f.LogicalResourceID), "/")
+
+	return fmt.Sprintf("%s (%s) %s: %s", id, f.ResourceType, f.Status, f.Reason)
+}
+
+// StackFailureError is returned if the execution of a ChangeSet ended in an unexpected stack status.
+type StackFailureError struct {
+	StackName string
+	Status    string
+	// Cause is the failed resource, it's only determined if IncludeNestedStacks is set.
+	Cause *ResourceFailure
+}
+
+func (e *StackFailureError) Error() string {
+	msg := fmt.Sprintf("unexpected stack status for stack %s: %s", e.StackName, e.Status)
+	if e.Cause != nil {
+		msg += ", caused by " + e.Cause.String()
+	}
+
+	return msg
+}
+
+// addNestedChanges adds the changes of the nested ChangeSets to the ChangeSet. Their logical IDs are prefixed with
+// the logical IDs of the nested stacks, e.g. `Network/Vpc`.
+func (c *Cloudformation) addNestedChanges(dcso *cloudformation.DescribeChangeSetOutput) error {
+	for _, change := range dcso.Changes {
+		rc := change.ResourceChange
+		if rc == nil || aws.StringValue(rc.ChangeSetId) == "" {
+			continue
+		}
+
+		nested, err := c.describeChangeSet(&cloudformation.DescribeChangeSetInput{ChangeSetName: rc.ChangeSetId})
+		if err != nil {
+			return fmt.Errorf("nested stack %s: %w", aws.StringValue(rc.LogicalResourceId), err)
+		}
+
+		for _, nc := range nested.Changes {
+			if nc.ResourceChange == nil {
+				continue
+			}
+
+			nestedChange := *nc
+			nestedResource := *nc.ResourceChange
+			nestedResource.LogicalResourceId = aws.String(aws.StringValue(rc.LogicalResourceId) + "/" +
+				aws.StringValue(nestedResource.LogicalResourceId))
+			nestedChange.ResourceChange = &nestedResource
+
+			dcso.Changes = append(dcso.Changes, &nestedChange)
+		}
+	}
+
+	return nil
+}
+
+// stackEventTracker follows the events of a stack and its nested stacks during a stack operation.
+type stackEventTracker struct {
+	c     *Cloudformation
+	since time.Time
+	seen  map[string]bool
+	// stacks are the tracked stacks in the order they were found, paths holds their nested stack path.
+	stacks []string
+	paths  map[string][]string
+	events map[string][]*cloudformation.StackEvent
+}
+
+// newStackEventTracker tracks the events of the current operation of the stack.
+func newStackEventTracker(c *Cloudformation, stack *cloudformation.Stack) *stackEventTracker {
+	since := aws.TimeValue(stack.CreationTime)
+	if stack.LastUpdatedTime != nil {
+		since = *stack.LastUpdatedTime
+	}
+
+	return &stackEventTracker{
+		c:      c,
+		since:  since,
+		seen:   map[string]bool{},
+		stacks: []string{c.StackName},
+		paths:  map[string][]string{c.StackName: nil},
+		events: map[string][]*cloudformation.StackEvent{},
+	}
+}
+
+// poll logs the new events of all tracked stacks and starts tracking nested stacks found in them.
+func (t *stackEventTracker) poll() error {
+	// nested stacks found in the events are appended to t.stacks and polled in the same run
+	for i := 0; i < len(t.stacks); i++ {
+		stack := t.stacks[i]
+
+		events, err := t.newEvents(stack)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			t.events[stack] = append(t.events[stack], e)
+			t.c.logger().Infof("%s (%s) %s %s", strings.Join(append(append([]string{}, t.paths[stack]...),
+				aws.StringValue(e.LogicalResourceId)), "/"), aws.StringValue(e.ResourceType),
+				aws.StringValue(e.ResourceStatus), aws.StringValue(e.ResourceStatusReason))
+
+			nested := aws.StringValue(e.PhysicalResourceId)
+			if aws.StringValue(e.ResourceType) != nestedStackType || nested == "" || nested == aws.StringValue(e.StackId) {
+				continue
+			}
+
+			if _, ok := t.paths[nested]; !ok {
+				t.paths[nested] = append(append([]string{}, t.paths[stack]...), aws.StringValue(e.LogicalResourceId))
+				t.stacks = append(t.stacks, nested)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newEvents returns the events of the stack since the start of the operation which weren't seen yet, oldest first.
+func (t *stackEventTracker) newEvents(stack string) ([]*cloudformation.StackEvent, error) {
+	var events []*cloudformation.StackEvent
+
+	input := &cloudformation.DescribeStackEventsInput{StackName: aws.String(stack)}
+
+	for {
+		out, err := t.c.CFClient.DescribeStackEvents(input)
+		if err != nil {
+			return nil, fmt.Errorf("error describing the events of stack %s: %w", stack, err)
+		}
+
+		// events are returned newest first, so everything after a seen or older event was seen already
+		done := false
+
+		for _, e := range out.StackEvents {
+			if aws.TimeValue(e.Timestamp).Before(t.since) || t.seen[aws.StringValue(e.EventId)] {
+				done = true
+
+				break
+			}
+
+			t.seen[aws.StringValue(e.EventId)] = true
+			events = append(events, e)
+		}
+
+		if done || out.NextToken == nil {
+			break
+		}
+
+		input.NextToken = out.NextToken
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
+
+// rootCause returns the first failed resource of the operation, following failed nested stacks down to the
+// resource which failed in them. It returns nil if no failure was found or t is nil.
+func (t *stackEventTracker) rootCause() *ResourceFailure {
+	if t == nil {
+		return nil
+	}
+
+	return t.failure(t.c.StackName)
+}
+
+func (t *stackEventTracker) failure(stack string) *ResourceFailure {
+	for _, e := range t.events[stack] {
+		status, reason := aws.StringValue(e.ResourceStatus), aws.StringValue(e.ResourceStatusReason)
+		if !strings.HasSuffix(status, "_FAILED") || aws.StringValue(e.PhysicalResourceId) == aws.StringValue(e.StackId) ||
+			strings.Contains(strings.ToLower(reason), "cancelled") {
+			continue
+		}
+
+		if _, ok := t.paths[aws.StringValue(e.PhysicalResourceId)]; ok && aws.StringValue(e.ResourceType) == nestedStackType {
+			if cause := t.failure(aws.StringValue(e.PhysicalResourceId)); cause != nil {
+				return cause
+			}
+		}
+
+		return &ResourceFailure{
+			Path:              t.paths[stack],
+			StackName:         aws.StringValue(e.StackName),
+			LogicalResourceID: aws.StringValue(e.LogicalResourceId),
+			ResourceType:      aws.StringValue(e.ResourceType),
+			Status:            status,
+			Reason:            reason,
+		}
+	}
+
+	return nil
+}
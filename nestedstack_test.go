@@ -0,0 +1,162 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+var nestedStackStart = time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+
+type mockNestedStackClient struct {
+	cloudformationiface.CloudFormationAPI
+	changeSets map[string][]*cloudformation.Change
+	events     map[string][]*cloudformation.StackEvent
+}
+
+func (m mockNestedStackClient) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	return &cloudformation.DescribeChangeSetOutput{
+		ChangeSetId: input.ChangeSetName,
+		Changes:     m.changeSets[aws.StringValue(input.ChangeSetName)],
+	}, nil
+}
+
+func (m mockNestedStackClient) DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{
+		StackName:       aws.String("test"),
+		StackStatus:     aws.String(cloudformation.StackStatusUpdateRollbackComplete),
+		CreationTime:    aws.Time(nestedStackStart.Add(-time.Hour)),
+		LastUpdatedTime: aws.Time(nestedStackStart),
+	}}}, nil
+}
+
+func (m mockNestedStackClient) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: m.events[aws.StringValue(input.StackName)]}, nil
+}
+
+func nestedChange(logicalID, resourceType, changeSetID string) *cloudformation.Change {
+	rc := resourceChange(cloudformation.ChangeActionModify, logicalID, "").ResourceChange
+	rc.ResourceType = aws.String(resourceType)
+
+	if changeSetID != "" {
+		rc.ChangeSetId = aws.String(changeSetID)
+	}
+
+	return &cloudformation.Change{ResourceChange: rc}
+}
+
+// stackEvent returns an event of the stack, minutes after the start of the update.
+func stackEvent(stackID, logicalID, resourceType, physicalID, status, reason string, minutes int) *cloudformation.StackEvent {
+	return &cloudformation.StackEvent{
+		EventId:              aws.String(stackID + logicalID + status),
+		StackId:              aws.String(stackID),
+		StackName:            aws.String(stackID),
+		LogicalResourceId:    aws.String(logicalID),
+		PhysicalResourceId:   aws.String(physicalID),
+		ResourceType:         aws.String(resourceType),
+		ResourceStatus:       aws.String(status),
+		ResourceStatusReason: aws.String(reason),
+		Timestamp:            aws.Time(nestedStackStart.Add(time.Duration(minutes) * time.Minute)),
+	}
+}
+
+func newMockNestedStackClient() mockNestedStackClient {
+	return mockNestedStackClient{
+		changeSets: map[string][]*cloudformation.Change{
+			"root": {
+				nestedChange("Network", nestedStackType, "nested"),
+				nestedChange("Topic", "AWS::SNS::Topic", ""),
+			},
+			"nested": {nestedChange("Vpc", "AWS::EC2::VPC", "")},
+		},
+		// events are returned newest first
+		events: map[string][]*cloudformation.StackEvent{
+			"test": {
+				stackEvent("arn:test", "test", nestedStackType, "arn:test", "UPDATE_ROLLBACK_IN_PROGRESS", "", 4),
+				stackEvent("arn:test", "Network", nestedStackType, "arn:network", "UPDATE_FAILED", "Embedded stack was not updated", 3),
+				stackEvent("arn:test", "Topic", "AWS::SNS::Topic", "topic", "UPDATE_FAILED", "Resource update cancelled", 2),
+				stackEvent("arn:test", "Network", nestedStackType, "arn:network", "UPDATE_IN_PROGRESS", "", 1),
+				stackEvent("arn:test", "Old", "AWS::SNS::Topic", "old", "CREATE_FAILED", "earlier deployment", -10),
+			},
+			"arn:network": {
+				stackEvent("arn:network", "Vpc", "AWS::EC2::VPC", "", "CREATE_FAILED", "VPC limit exceeded", 2),
+				stackEvent("arn:network", "Vpc", "AWS::EC2::VPC", "", "CREATE_IN_PROGRESS", "", 1),
+			},
+		},
+	}
+}
+
+func TestCloudformation_describeChangeSet_nestedStacks(t *testing.T) {
+	tests := []struct {
+		name                string
+		includeNestedStacks bool
+		want                []string
+	}{
+		{name: "Test nested changes are included", includeNestedStacks: true, want: []string{"Network", "Topic", "Network/Vpc"}},
+		{name: "Test nested changes are ignored", want: []string{"Network", "Topic"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cloudformation{CFClient: newMockNestedStackClient(), StackName: "test", IncludeNestedStacks: tt.includeNestedStacks}
+
+			dcso, err := c.describeChangeSet(&cloudformation.DescribeChangeSetInput{ChangeSetName: aws.String("root")})
+			if err != nil {
+				t.Fatalf("describeChangeSet() unexpected error = %v", err)
+			}
+
+			var got []string
+			for _, change := range SummarizeChangeSet(dcso).Changes {
+				got = append(got, change.LogicalResourceID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("describeChangeSet() changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudformation_waitForChangeSetExecution_rootCause(t *testing.T) {
+	tests := []struct {
+		name                string
+		includeNestedStacks bool
+		want                *ResourceFailure
+	}{
+		{
+			name:                "Test failure in nested stack",
+			includeNestedStacks: true,
+			want: &ResourceFailure{
+				Path:              []string{"Network"},
+				StackName:         "arn:network",
+				LogicalResourceID: "Vpc",
+				ResourceType:      "AWS::EC2::VPC",
+				Status:            "CREATE_FAILED",
+				Reason:            "VPC limit exceeded",
+			},
+		},
+		{name: "Test without nested stacks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cloudformation{CFClient: newMockNestedStackClient(), StackName: "test", IncludeNestedStacks: tt.includeNestedStacks}
+
+			var failure *StackFailureError
+
+			err := c.waitForChangeSetExecution("cs")
+			if !errors.As(err, &failure) {
+				t.Fatalf("waitForChangeSetExecution() error = %v, want *StackFailureError", err)
+			}
+
+			if !reflect.DeepEqual(failure.Cause, tt.want) {
+				t.Errorf("waitForChangeSetExecution() cause = %v, want %v", failure.Cause, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package godeploycfn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// StackConfiguration holds everything besides the template which is needed to deploy a stack.
+type StackConfiguration struct {
+	// Parameters and Tags are nil if none were given, and empty if the file contained an empty section.
+	Parameters []*cloudformation.Parameter
+	Tags       []*cloudformation.Tag
+	// StackPolicy is the JSON stack policy document, empty if none was given.
+	StackPolicy string
+}
+
+// Apply configures c to deploy with the parameters, tags and stack policy of s. Only the fields which were
+// given are set, so e.g. a stack policy configured on c is kept when applying a KEY=VALUE file.
+func (s *StackConfiguration) Apply(c *Cloudformation) {
+	if s.Parameters != nil {
+		c.Parameters = s.Parameters
+	}
+
+	if s.Tags != nil {
+		c.Tags = s.Tags
+	}
+
+	if s.StackPolicy != "" {
+		c.StackPolicy = s.StackPolicy
+	}
+}
+
+// cliParameter is a single entry of the AWS CLI `--parameters file://...` format.
+type cliParameter struct {
+	ParameterKey     *string
+	ParameterValue   *string
+	UsePreviousValue *bool
+	ResolvedValue    *string
+}
+
+// ParseCLIParameters parses parameters in the AWS CLI format
+// `[{"ParameterKey": "...", "ParameterValue": "..."}]`.
+func ParseCLIParameters(r io.Reader) (*StackConfiguration, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var entries []cliParameter
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error parsing AWS CLI parameters: %w", err)
+	}
+
+	cfg := &StackConfiguration{}
+	seen := map[string]bool{}
+
+	for i, e := range entries {
+		if aws.StringValue(e.ParameterKey) == "" {
+			return nil, fmt.Errorf("parameter #%d has no ParameterKey", i+1)
+		}
+
+		key := *e.ParameterKey
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate definition of parameter %q", key)
+		}
+
+		seen[key] = true
+
+		if e.ParameterValue == nil && !aws.BoolValue(e.UsePreviousValue) {
+			return nil, fmt.Errorf("parameter %q has neither ParameterValue nor UsePreviousValue", key)
+		}
+
+		cfg.Parameters = append(cfg.Parameters, &cloudformation.Parameter{
+			ParameterKey:     e.ParameterKey,
+			ParameterValue:   e.ParameterValue,
+			UsePreviousValue: e.UsePreviousValue,
+		})
+	}
+
+	return cfg, nil
+}
+
+// ParseTemplateConfiguration parses a CodePipeline template configuration file
+// `{"Parameters": {...}, "Tags": {...}, "StackPolicy": {...}}`.
+func ParseTemplateConfiguration(r io.Reader) (*StackConfiguration, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template configuration: %w", err)
+	}
+
+	sections, err := decodeObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template configuration: %w", err)
+	}
+
+	cfg := &StackConfiguration{}
+
+	for _, section := range sections {
+		switch section.key {
+		case "Parameters":
+			var params []keyValue
+
+			if params, err = decodeStringObject(section.value); err != nil {
+				return nil, fmt.Errorf("error parsing template configuration Parameters: %w", err)
+			}
+
+			cfg.Parameters = []*cloudformation.Parameter{}
+
+			for _, p := range params {
+				cfg.Parameters = append(cfg.Parameters, &cloudformation.Parameter{
+					ParameterKey:   aws.String(p.key),
+					ParameterValue: aws.String(p.str),
+				})
+			}
+		case "Tags":
+			var tags []keyValue
+
+			if tags, err = decodeStringObject(section.value); err != nil {
+				return nil, fmt.Errorf("error parsing template configuration Tags: %w", err)
+			}
+
+			cfg.Tags = []*cloudformation.Tag{}
+
+			for _, t := range tags {
+				cfg.Tags = append(cfg.Tags, &cloudformation.Tag{Key: aws.String(t.key), Value: aws.String(t.str)})
+			}
+		case "StackPolicy":
+			var buf bytes.Buffer
+
+			if err = json.Compact(&buf, section.value); err != nil {
+				return nil, fmt.Errorf("error parsing template configuration StackPolicy: %w", err)
+			}
+
+			cfg.StackPolicy = buf.String()
+		default:
+			return nil, fmt.Errorf("unknown key %q in template configuration", section.key)
+		}
+	}
+
+	return cfg, nil
+}
+
+// ParseKeyValueParameters parses `KEY=VALUE` lines. Empty lines and lines starting with `#` are ignored,
+// values may be surrounded by single or double quotes.
+func ParseKeyValueParameters(r io.Reader) (*StackConfiguration, error) {
+	cfg := &StackConfiguration{}
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty parameter key", lineNo)
+		}
+
+		if first, ok := seen[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate definition of parameter %q, first defined in line %d", lineNo, key, first)
+		}
+
+		seen[key] = lineNo
+
+		cfg.Parameters = append(cfg.Parameters, &cloudformation.Parameter{
+			ParameterKey:   aws.String(key),
+			ParameterValue: aws.String(unquote(strings.TrimSpace(value))),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading parameters: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// LoadStackConfiguration reads a parameter file in any of the supported formats. JSON files starting with `[`
+// are AWS CLI parameters, other JSON files template configurations, everything else KEY=VALUE files.
+func LoadStackConfiguration(path string) (*StackConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading parameter file: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+
+	var cfg *StackConfiguration
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		cfg, err = ParseCLIParameters(bytes.NewReader(data))
+	case bytes.HasPrefix(trimmed, []byte("{")) || strings.EqualFold(filepath.Ext(path), ".json"):
+		cfg, err = ParseTemplateConfiguration(bytes.NewReader(data))
+	default:
+		cfg, err = ParseKeyValueParameters(bytes.NewReader(data))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+type keyValue struct {
+	key   string
+	value json.RawMessage
+	str   string
+}
+
+// decodeObject decodes a JSON object into its members, keeping their order and rejecting duplicate keys.
+func decodeObject(data []byte) ([]keyValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("expected a JSON object")
+	}
+
+	var members []keyValue
+
+	seen := map[string]bool{}
+
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		key, _ := tok.(string)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate definition of %q", key)
+		}
+
+		seen[key] = true
+
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON value for %q: %w", key, err)
+		}
+
+		members = append(members, keyValue{key: key, value: value})
+	}
+
+	if _, err = dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	return members, nil
+}
+
+// decodeStringObject decodes a JSON object whose values must all be strings.
+func decodeStringObject(data json.RawMessage) ([]keyValue, error) {
+	members, err := decodeObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range members {
+		if err = json.Unmarshal(members[i].value, &members[i].str); err != nil {
+			return nil, fmt.Errorf("value of %q must be a string", members[i].key)
+		}
+	}
+
+	return members, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
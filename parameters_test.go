@@ -0,0 +1,250 @@
+package godeploycfn
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func parameterMap(params []*cloudformation.Parameter) map[string]string {
+	m := map[string]string{}
+	for _, p := range params {
+		m[*p.ParameterKey] = aws.StringValue(p.ParameterValue)
+	}
+
+	return m
+}
+
+func TestParseCLIParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "Test valid parameters",
+			input: `[{"ParameterKey":"Env","ParameterValue":"prod"},{"ParameterKey":"Size","UsePreviousValue":true}]`,
+			want:  map[string]string{"Env": "prod", "Size": ""},
+		},
+		{
+			name:    "Test unknown key",
+			input:   `[{"ParameterKey":"Env","ParameterValue":"prod","Value":"x"}]`,
+			wantErr: `unknown field "Value"`,
+		},
+		{
+			name:    "Test duplicate parameter",
+			input:   `[{"ParameterKey":"Env","ParameterValue":"prod"},{"ParameterKey":"Env","ParameterValue":"dev"}]`,
+			wantErr: `duplicate definition of parameter "Env"`,
+		},
+		{
+			name:    "Test missing value",
+			input:   `[{"ParameterKey":"Env"}]`,
+			wantErr: "neither ParameterValue nor UsePreviousValue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCLIParameters(strings.NewReader(tt.input))
+			checkConfig(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestParseTemplateConfiguration(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		want       map[string]string
+		wantTags   int
+		wantPolicy string
+		wantErr    string
+	}{
+		{
+			name: "Test full configuration",
+			input: `{
+				"Parameters": {"Env": "prod"},
+				"Tags": {"team": "platform", "cost-center": "42"},
+				"StackPolicy": {"Statement": [{"Effect": "Allow", "Action": "Update:*", "Principal": "*", "Resource": "*"}]}
+			}`,
+			want:       map[string]string{"Env": "prod"},
+			wantTags:   2,
+			wantPolicy: `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`,
+		},
+		{
+			name:    "Test unknown section",
+			input:   `{"Parameters": {}, "Tag": {}}`,
+			wantErr: `unknown key "Tag"`,
+		},
+		{
+			name:    "Test duplicate parameter",
+			input:   `{"Parameters": {"Env": "prod", "Env": "dev"}}`,
+			wantErr: `duplicate definition of "Env"`,
+		},
+		{
+			name:    "Test non-string value",
+			input:   `{"Parameters": {"Size": 3}}`,
+			wantErr: `value of "Size" must be a string`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTemplateConfiguration(strings.NewReader(tt.input))
+			checkConfig(t, got, err, tt.want, tt.wantErr)
+
+			if err != nil {
+				return
+			}
+
+			if len(got.Tags) != tt.wantTags {
+				t.Errorf("got %d tags, want %d", len(got.Tags), tt.wantTags)
+			}
+
+			if got.StackPolicy != tt.wantPolicy {
+				t.Errorf("StackPolicy = %s, want %s", got.StackPolicy, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestParseKeyValueParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "Test valid file",
+			input: "# comment\nEnv=prod\n\nName = \"my = name\"\nEmpty=\n",
+			want:  map[string]string{"Env": "prod", "Name": "my = name", "Empty": ""},
+		},
+		{
+			name:    "Test missing separator",
+			input:   "Env=prod\nSize\n",
+			wantErr: "line 2: expected KEY=VALUE",
+		},
+		{
+			name:    "Test duplicate parameter",
+			input:   "Env=prod\nEnv=dev\n",
+			wantErr: `line 2: duplicate definition of parameter "Env", first defined in line 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyValueParameters(strings.NewReader(tt.input))
+			checkConfig(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadStackConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cli.json":    `[{"ParameterKey":"Env","ParameterValue":"cli"}]`,
+		"config.json": `{"Parameters":{"Env":"config"}}`,
+		"params.env":  "Env=env\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{"cli.json": "cli", "config.json": "config", "params.env": "env"} {
+		cfg, err := LoadStackConfiguration(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("LoadStackConfiguration(%s) unexpected error = %v", name, err)
+		}
+
+		if got := parameterMap(cfg.Parameters)["Env"]; got != want {
+			t.Errorf("LoadStackConfiguration(%s) Env = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestStackConfiguration_Apply(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func(r io.Reader) (*StackConfiguration, error)
+		input      string
+		wantTags   int
+		wantPolicy string
+	}{
+		{
+			name:       "Test KEY=VALUE file keeps tags and policy",
+			parse:      ParseKeyValueParameters,
+			input:      "Env=prod\n",
+			wantTags:   1,
+			wantPolicy: denyReplacePolicy,
+		},
+		{
+			name:       "Test AWS CLI file keeps tags and policy",
+			parse:      ParseCLIParameters,
+			input:      `[{"ParameterKey":"Env","ParameterValue":"prod"}]`,
+			wantTags:   1,
+			wantPolicy: denyReplacePolicy,
+		},
+		{
+			name:       "Test template configuration replaces them",
+			parse:      ParseTemplateConfiguration,
+			input:      `{"Parameters":{"Env":"prod"},"Tags":{},"StackPolicy":` + overridePolicy + `}`,
+			wantPolicy: overridePolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error = %v", err)
+			}
+
+			c := &Cloudformation{
+				Tags:        []*cloudformation.Tag{{Key: aws.String("team"), Value: aws.String("platform")}},
+				StackPolicy: denyReplacePolicy,
+			}
+			cfg.Apply(c)
+
+			if parameterMap(c.Parameters)["Env"] != "prod" || len(c.Tags) != tt.wantTags || c.StackPolicy != tt.wantPolicy {
+				t.Errorf("Apply() = parameters %v, tags %v, policy %s", c.Parameters, c.Tags, c.StackPolicy)
+			}
+		})
+	}
+}
+
+func checkConfig(t *testing.T, got *StackConfiguration, err error, want map[string]string, wantErr string) {
+	t.Helper()
+
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("error = %v, want error containing %q", err, wantErr)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error = %v", err)
+	}
+
+	gotParams := parameterMap(got.Parameters)
+	if len(gotParams) != len(want) {
+		t.Fatalf("got parameters %v, want %v", gotParams, want)
+	}
+
+	for k, v := range want {
+		if gotParams[k] != v {
+			t.Errorf("parameter %s = %q, want %q", k, gotParams[k], v)
+		}
+	}
+}
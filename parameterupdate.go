@@ -0,0 +1,176 @@
+package godeploycfn
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// UpdateParameters changes parameter values of the deployed stack without sending its template. The ChangeSet
+// uses the previous template, the given values and the previous value of all other parameters, and is approved,
+// executed and recorded in the Journal like a ChangeSet of CloudFormationDeploy. Tags and capabilities of the
+// stack are kept unless Tags is set.
+func (c *Cloudformation) UpdateParameters(parameters []*cloudformation.Parameter) error {
+	if err := c.validateStackPolicyOptions(); err != nil {
+		return err
+	}
+
+	stack, err := c.describeStack()
+	if err != nil {
+		return err
+	}
+
+	if stack == nil {
+		return fmt.Errorf("stack %s does not exist", c.StackName)
+	}
+
+	request, deployed, err := mergeParameters(stack, parameters)
+	if err != nil {
+		return err
+	}
+
+	if request == nil {
+		c.logger().Info("Parameter values are unchanged. Skipping ChangeSet.")
+
+		return c.syncUnchangedStack()
+	}
+
+	templateBody, err := c.deployedTemplate()
+	if err != nil {
+		return err
+	}
+
+	// deployment has the values of all parameters and the tags of the stack unless Tags is set, so its hash and
+	// record match a CloudFormationDeploy of the deployed template with these values.
+	deployment := *c
+	deployment.Parameters = deployed
+
+	if deployment.Tags == nil {
+		deployment.Tags = stack.Tags
+	}
+
+	namedIAM := false
+
+	for _, capability := range stack.Capabilities {
+		namedIAM = namedIAM || aws.StringValue(capability) == cloudformation.CapabilityCapabilityNamedIam
+	}
+
+	var record *DeploymentRecord
+	if c.Journal != nil {
+		record = deployment.newDeploymentRecord(templateBody, namedIAM)
+	}
+
+	err = deployment.updateParameters(stack, templateBody, namedIAM, request, record)
+	if record != nil {
+		return c.recordDeployment(record, err)
+	}
+
+	return err
+}
+
+func (c *Cloudformation) updateParameters(stack *cloudformation.Stack, templateBody string, namedIAM bool,
+	parameters []*cloudformation.Parameter, record *DeploymentRecord,
+) error {
+	hash, err := c.DeploymentHash(templateBody, namedIAM)
+	if err != nil {
+		return err
+	}
+
+	tags := c.Tags
+	if c.SkipUnchanged || hasTag(stack.Tags, DeploymentHashTag) {
+		tags = c.withDeploymentHashTag(hash)
+	}
+
+	if err = c.refuseDrifted(); err != nil {
+		return err
+	}
+
+	id, err := c.changeSetID(hash)
+	if err != nil {
+		return err
+	}
+
+	csn := fmt.Sprintf("%s-%s", trimStackName(c.StackName, 91), id)
+
+	//nolint
+	cs, err := c.createChangeSetAndWait(&cloudformation.CreateChangeSetInput{
+		Capabilities:        stack.Capabilities,
+		ChangeSetName:       aws.String(csn),
+		ChangeSetType:       aws.String(cloudformation.ChangeSetTypeUpdate),
+		ClientToken:         aws.String(requestToken(csn)),
+		Description:         aws.String(changeSetDescription(c.deploymentKind(), hash)),
+		IncludeNestedStacks: aws.Bool(c.IncludeNestedStacks),
+		Parameters:          parameters,
+		StackName:           aws.String(trimStackName(c.StackName, 128)),
+		Tags:                tags,
+		UsePreviousTemplate: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.executeDeployment(cs, record)
+}
+
+// mergeParameters returns the parameters of a ChangeSet changing the given values of the stack's parameters,
+// or nil if they are unchanged, and all parameter values after the update. The stack describes the values of
+// NoEcho parameters as redactedValue: given values of NoEcho parameters can't be compared, so they always count
+// as changed, and NoEcho parameters which aren't given keep their previous value, but are redactedValue in the
+// returned values. Their DeploymentHash therefore never matches a deployment with the actual value, and Redeploy
+// keeps the previous value.
+func mergeParameters(stack *cloudformation.Stack, parameters []*cloudformation.Parameter) (
+	request, deployed []*cloudformation.Parameter, err error,
+) {
+	values := map[string]string{}
+	declared := map[string]bool{}
+
+	for _, p := range stack.Parameters {
+		declared[aws.StringValue(p.ParameterKey)] = true
+	}
+
+	for _, p := range parameters {
+		key := aws.StringValue(p.ParameterKey)
+		if !declared[key] {
+			return nil, nil, fmt.Errorf("stack %s has no parameter %s", aws.StringValue(stack.StackName), key)
+		}
+
+		values[key] = aws.StringValue(p.ParameterValue)
+	}
+
+	changed := false
+
+	for _, p := range stack.Parameters {
+		key := aws.StringValue(p.ParameterKey)
+		value, ok := values[key]
+		noEcho := aws.StringValue(p.ParameterValue) == redactedValue
+
+		if !ok || (!noEcho && value == aws.StringValue(p.ParameterValue)) {
+			request = append(request, &cloudformation.Parameter{ParameterKey: p.ParameterKey, UsePreviousValue: aws.Bool(true)})
+			deployed = append(deployed, &cloudformation.Parameter{ParameterKey: p.ParameterKey, ParameterValue: p.ParameterValue})
+
+			continue
+		}
+
+		changed = true
+
+		request = append(request, &cloudformation.Parameter{ParameterKey: p.ParameterKey, ParameterValue: aws.String(value)})
+		deployed = append(deployed, &cloudformation.Parameter{ParameterKey: p.ParameterKey, ParameterValue: aws.String(value)})
+	}
+
+	if !changed {
+		return nil, deployed, nil
+	}
+
+	return request, deployed, nil
+}
+
+func hasTag(tags []*cloudformation.Tag, key string) bool {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package godeploycfn
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestCloudformation_UpdateParameters(t *testing.T) {
+	tests := []struct {
+		name         string
+		key, value   string
+		wantErr      bool
+		wantExecuted bool
+	}{
+		{name: "Test changed parameter", key: "Env", value: "staging", wantExecuted: true},
+		{name: "Test unchanged parameter", key: "Env", value: "prod"},
+		{name: "Test unknown parameter", key: "Region", value: "eu-central-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockRedeployClient()
+			client.stack.Parameters = []*cloudformation.Parameter{
+				{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+				{ParameterKey: aws.String("Size"), ParameterValue: aws.String("small")},
+			}
+			c := &Cloudformation{CFClient: client, StackName: "test"}
+
+			err := c.UpdateParameters([]*cloudformation.Parameter{{ParameterKey: aws.String(tt.key), ParameterValue: aws.String(tt.value)}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateParameters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if *client.executed != tt.wantExecuted {
+				t.Errorf("ChangeSet executed = %v, want %v", *client.executed, tt.wantExecuted)
+			}
+
+			if !tt.wantExecuted {
+				return
+			}
+
+			created := client.created
+			if !aws.BoolValue(created.UsePreviousTemplate) || created.TemplateBody != nil {
+				t.Errorf("CreateChangeSet() must use the previous template, got %+v", created)
+			}
+
+			params := created.Parameters
+			if len(params) != 2 || aws.StringValue(params[0].ParameterValue) != tt.value ||
+				params[1].ParameterValue != nil || !aws.BoolValue(params[1].UsePreviousValue) {
+				t.Errorf("CreateChangeSet() parameters = %v", params)
+			}
+		})
+	}
+}
+
+func TestCloudformation_UpdateParameters_keepsTags(t *testing.T) {
+	client := newMockRedeployClient()
+	client.stack.Parameters = []*cloudformation.Parameter{{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")}}
+	client.stack.Tags = []*cloudformation.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String(DeploymentHashTag), Value: aws.String("old")},
+	}
+	c := &Cloudformation{CFClient: client, StackName: "test"}
+
+	if err := c.UpdateParameters([]*cloudformation.Parameter{{ParameterKey: aws.String("Env"), ParameterValue: aws.String("staging")}}); err != nil {
+		t.Fatalf("UpdateParameters() unexpected error = %v", err)
+	}
+
+	tags := client.created.Tags
+	if len(tags) != 2 || aws.StringValue(tags[0].Key) != "team" || aws.StringValue(tags[1].Key) != DeploymentHashTag ||
+		aws.StringValue(tags[1].Value) == "old" {
+		t.Errorf("CreateChangeSet() tags = %v, want team and the new deployment hash", tags)
+	}
+}
+
+func TestCloudformation_UpdateParameters_noEcho(t *testing.T) {
+	tests := []struct {
+		name         string
+		key, value   string
+		wantPassword *string
+	}{
+		{name: "Test given NoEcho value is passed on", key: "Password", value: redactedValue, wantPassword: aws.String(redactedValue)},
+		{name: "Test other NoEcho values keep their previous value", key: "Env", value: "staging"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockRedeployClient()
+			client.stack.Parameters = []*cloudformation.Parameter{
+				{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+				{ParameterKey: aws.String("Password"), ParameterValue: aws.String(redactedValue)},
+			}
+			journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+			c := &Cloudformation{CFClient: client, StackName: "test", Journal: journal}
+
+			if err := c.UpdateParameters([]*cloudformation.Parameter{{ParameterKey: aws.String(tt.key), ParameterValue: aws.String(tt.value)}}); err != nil {
+				t.Fatalf("UpdateParameters() unexpected error = %v", err)
+			}
+
+			password := client.created.Parameters[1]
+			if !reflect.DeepEqual(password.ParameterValue, tt.wantPassword) || aws.BoolValue(password.UsePreviousValue) != (tt.wantPassword == nil) {
+				t.Errorf("CreateChangeSet() Password = %v", password)
+			}
+
+			history, err := journal.History("test")
+			if err != nil || len(history) != 1 {
+				t.Fatalf("History() = %v, %v", history, err)
+			}
+
+			if got := aws.StringValue(history[0].Parameters[1].ParameterValue); got != redactedValue {
+				t.Errorf("recorded Password = %q, want it redacted", got)
+			}
+		})
+	}
+}
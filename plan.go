@@ -0,0 +1,255 @@
+package godeploycfn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// DeploymentPlan describes what CloudFormationDeploy would change.
+type DeploymentPlan struct {
+	ChangeSetSummary
+	ChangeSetType string `json:"changeSetType,omitempty"`
+	// EstimatedCostURL links to the AWS Pricing Calculator, it's only set if EstimateCost is enabled.
+	EstimatedCostURL string `json:"estimatedCostUrl,omitempty"`
+	// TemplateHash is the DeploymentHash of the template, parameters and tags.
+	TemplateHash string `json:"templateHash"`
+	// Parameters are the parameters of the ChangeSet, values of NoEcho parameters are redacted.
+	Parameters []*cloudformation.Parameter `json:"parameters,omitempty"`
+	CreatedAt  time.Time                   `json:"createdAt"`
+	// StackStatus and StackLastUpdatedTime describe the stack after the ChangeSet was created. Apply refuses
+	// to execute the ChangeSet if they changed.
+	StackStatus          string     `json:"stackStatus,omitempty"`
+	StackLastUpdatedTime *time.Time `json:"stackLastUpdatedTime,omitempty"`
+	// ChangeSet is the described ChangeSet. It's nil if there are no changes or the plan was read from a file.
+	ChangeSet *cloudformation.DescribeChangeSetOutput `json:"-"`
+}
+
+// HasChanges returns true if the plan contains a ChangeSet.
+func (p *DeploymentPlan) HasChanges() bool {
+	return p.ChangeSetID != ""
+}
+
+// Plan creates a ChangeSet for the template like CloudFormationDeploy does, but doesn't execute it.
+// The ChangeSet is left in place, so it can be executed later with Apply, or deleted.
+func (c *Cloudformation) Plan(templateBody string, namedIAM bool) (*DeploymentPlan, error) {
+	cs, err := c.createChangeSet(templateBody, namedIAM, changeSetKindPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeploymentPlan{
+		ChangeSetSummary: ChangeSetSummary{StackName: c.StackName, Changes: []ResourceChangeSummary{}},
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	if cs != nil {
+		var dcso *cloudformation.DescribeChangeSetOutput
+		if dcso, err = c.describeChangeSet(cs.describeInput); err != nil {
+			return nil, err
+		}
+
+		if plan, err = c.newDeploymentPlan(dcso, cs.changeSetType); err != nil {
+			return nil, err
+		}
+	}
+
+	plan.Parameters = redactParameters(templateBody, c.Parameters)
+
+	if plan.TemplateHash, err = c.DeploymentHash(templateBody, namedIAM); err != nil {
+		return nil, err
+	}
+
+	if c.EstimateCost {
+		if plan.EstimatedCostURL, err = c.EstimateTemplateCost(templateBody); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// newDeploymentPlan returns the plan of the described ChangeSet with the current status of the stack. The
+// TemplateHash is read from the description of the ChangeSet, and the Parameters are the ones CloudFormation
+// describes, with NoEcho values masked.
+func (c *Cloudformation) newDeploymentPlan(dcso *cloudformation.DescribeChangeSetOutput, changeSetType string) (*DeploymentPlan, error) {
+	_, hash := parseChangeSetDescription(aws.StringValue(dcso.Description))
+
+	plan := &DeploymentPlan{
+		ChangeSetSummary: SummarizeChangeSet(dcso),
+		ChangeSetType:    changeSetType,
+		TemplateHash:     hash,
+		Parameters:       dcso.Parameters,
+		CreatedAt:        time.Now().UTC(),
+		ChangeSet:        dcso,
+	}
+	plan.StackName = c.StackName
+
+	stack, err := c.describeStack()
+	if err != nil {
+		return nil, err
+	}
+
+	if stack != nil {
+		plan.StackStatus = aws.StringValue(stack.StackStatus)
+		plan.StackLastUpdatedTime = stack.LastUpdatedTime
+	}
+
+	return plan, nil
+}
+
+// EstimateTemplateCost returns the URL of the AWS Pricing Calculator estimating the monthly costs of the
+// resources of the template with the configured Parameters.
+func (c *Cloudformation) EstimateTemplateCost(templateBody string) (string, error) {
+	out, err := c.CFClient.EstimateTemplateCost(&cloudformation.EstimateTemplateCostInput{
+		Parameters:   c.Parameters,
+		TemplateBody: aws.String(templateBody),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error estimating template cost: %w", err)
+	}
+
+	return aws.StringValue(out.Url), nil
+}
+
+// ErrStalePlan is returned by Apply if the ChangeSet or the stack changed since the plan was created,
+// or the plan is older than MaxPlanAge.
+var ErrStalePlan = errors.New("plan is stale")
+
+// Save writes the plan to a JSON file.
+func (p *DeploymentPlan) Save(filename string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+
+	if err = os.WriteFile(filename, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPlan reads a plan written by Save.
+func LoadPlan(filename string) (*DeploymentPlan, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan: %w", err)
+	}
+
+	p := &DeploymentPlan{}
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("error parsing plan: %w", err)
+	}
+
+	return p, nil
+}
+
+// Apply executes the ChangeSet of a plan created by Plan. It returns an error wrapping ErrStalePlan if the
+// ChangeSet was deleted or can't be executed anymore, the stack was updated or changed its status since
+// the plan was created, or the plan is older than MaxPlanAge. The deployment is recorded in the Journal if it's set.
+func (c *Cloudformation) Apply(plan *DeploymentPlan) error {
+	if c.Journal == nil {
+		return c.apply(plan)
+	}
+
+	record := &DeploymentRecord{
+		StackName:    c.StackName,
+		TemplateHash: plan.TemplateHash,
+		Parameters:   plan.Parameters,
+		ChangeSetID:  plan.ChangeSetID,
+		Changes:      plan.Changes,
+		StartedAt:    time.Now().UTC(),
+		Metadata:     c.JournalMetadata,
+	}
+
+	return c.recordDeployment(record, c.apply(plan))
+}
+
+// apply implements Apply.
+func (c *Cloudformation) apply(plan *DeploymentPlan) error {
+	if plan.StackName != c.StackName {
+		return fmt.Errorf("plan is for stack %s, not %s", plan.StackName, c.StackName)
+	}
+
+	if c.MaxPlanAge > 0 && time.Since(plan.CreatedAt) > c.MaxPlanAge {
+		return fmt.Errorf("%w: it was created at %s and is older than %s", ErrStalePlan,
+			plan.CreatedAt.Format(time.RFC3339), c.MaxPlanAge)
+	}
+
+	if err := c.validateStackPolicyOptions(); err != nil {
+		return err
+	}
+
+	if !plan.HasChanges() {
+		return c.syncUnchangedStack()
+	}
+
+	if err := c.checkPlan(plan); err != nil {
+		return err
+	}
+
+	//nolint
+	dcsi := &cloudformation.DescribeChangeSetInput{
+		ChangeSetName: aws.String(plan.ChangeSetID),
+		StackName:     aws.String(c.StackName),
+	}
+
+	if err := c.approveChangeSet(dcsi, plan.ChangeSetType); err != nil {
+		return err
+	}
+
+	if err := c.executeWithStackPolicy(plan.ChangeSetID, plan.ChangeSetType); err != nil {
+		return err
+	}
+
+	return c.syncTerminationProtection()
+}
+
+// checkPlan verifies that the ChangeSet can still be executed and the stack didn't change.
+func (c *Cloudformation) checkPlan(plan *DeploymentPlan) error {
+	dcso, err := c.CFClient.DescribeChangeSet(&cloudformation.DescribeChangeSetInput{
+		ChangeSetName: aws.String(plan.ChangeSetID),
+		StackName:     aws.String(c.StackName),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == cloudformation.ErrCodeChangeSetNotFoundException {
+			return fmt.Errorf("%w: change set %s was deleted", ErrStalePlan, plan.ChangeSetName)
+		}
+
+		return fmt.Errorf("error describing the ChangeSet: %w", err)
+	}
+
+	if aws.StringValue(dcso.Status) != cloudformation.ChangeSetStatusCreateComplete ||
+		aws.StringValue(dcso.ExecutionStatus) != cloudformation.ExecutionStatusAvailable {
+		return fmt.Errorf("%w: change set %s has status %s and execution status %s", ErrStalePlan,
+			plan.ChangeSetName, aws.StringValue(dcso.Status), aws.StringValue(dcso.ExecutionStatus))
+	}
+
+	stack, err := c.describeStack()
+	if err != nil {
+		return err
+	}
+
+	if stack == nil {
+		return fmt.Errorf("%w: stack was deleted", ErrStalePlan)
+	}
+
+	if status := aws.StringValue(stack.StackStatus); status != plan.StackStatus {
+		return fmt.Errorf("%w: stack status changed from %s to %s", ErrStalePlan, plan.StackStatus, status)
+	}
+
+	planned, current := plan.StackLastUpdatedTime, stack.LastUpdatedTime
+	if (planned == nil) != (current == nil) || (planned != nil && !planned.Equal(*current)) {
+		return fmt.Errorf("%w: stack was updated at %s", ErrStalePlan, aws.TimeValue(current).Format(time.RFC3339))
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package godeploycfn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockPlanClient struct {
+	cloudformationiface.CloudFormationAPI
+	changes  []*cloudformation.Change
+	executed *bool
+	stack    *cloudformation.Stack
+	deleted  *bool
+}
+
+func newMockPlanClient(changes ...*cloudformation.Change) mockPlanClient {
+	return mockPlanClient{
+		changes:  changes,
+		executed: new(bool),
+		stack: &cloudformation.Stack{
+			StackName:       aws.String("test"),
+			StackStatus:     aws.String(cloudformation.StackStatusUpdateComplete),
+			LastUpdatedTime: aws.Time(time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)),
+		},
+		deleted: new(bool),
+	}
+}
+
+func (m mockPlanClient) ValidateTemplate(*cloudformation.ValidateTemplateInput) (*cloudformation.ValidateTemplateOutput, error) {
+	return &cloudformation.ValidateTemplateOutput{}, nil
+}
+
+func (m mockPlanClient) DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	stack := *m.stack
+
+	return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{&stack}}, nil
+}
+
+func (m mockPlanClient) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	return &cloudformation.CreateChangeSetOutput{Id: aws.String("arn:" + *input.ChangeSetName)}, nil
+}
+
+func (m mockPlanClient) WaitUntilChangeSetCreateCompleteWithContext(aws.Context, *cloudformation.DescribeChangeSetInput,
+	...request.WaiterOption,
+) error {
+	return nil
+}
+
+func (m mockPlanClient) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	if *m.deleted {
+		return nil, awserr.New(cloudformation.ErrCodeChangeSetNotFoundException, "ChangeSet [cs] does not exist", nil)
+	}
+
+	return &cloudformation.DescribeChangeSetOutput{
+		ChangeSetId:     input.ChangeSetName,
+		ChangeSetName:   aws.String("cs"),
+		StackName:       input.StackName,
+		Status:          aws.String(cloudformation.ChangeSetStatusCreateComplete),
+		ExecutionStatus: aws.String(cloudformation.ExecutionStatusAvailable),
+		Changes:         m.changes,
+	}, nil
+}
+
+func (m mockPlanClient) ExecuteChangeSet(*cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+	*m.executed = true
+
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (m mockPlanClient) EstimateTemplateCost(input *cloudformation.EstimateTemplateCostInput) (*cloudformation.EstimateTemplateCostOutput, error) {
+	return &cloudformation.EstimateTemplateCostOutput{
+		Url: aws.String("https://calculator.s3.amazonaws.com/calc5.html?key=" + aws.StringValue(input.Parameters[0].ParameterValue)),
+	}, nil
+}
+
+func TestCloudformation_Plan(t *testing.T) {
+	tests := []struct {
+		name         string
+		estimateCost bool
+		wantCostURL  string
+	}{
+		{name: "Test plan without cost estimation"},
+		{name: "Test plan with cost estimation", estimateCost: true, wantCostURL: "https://calculator.s3.amazonaws.com/calc5.html?key=prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", ""))
+			c := &Cloudformation{
+				CFClient:     client,
+				StackName:    "test",
+				Parameters:   []*cloudformation.Parameter{{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")}},
+				EstimateCost: tt.estimateCost,
+			}
+
+			plan, err := c.Plan(hashTemplate, false)
+			if err != nil {
+				t.Fatalf("Plan() unexpected error = %v", err)
+			}
+
+			if !plan.HasChanges() || len(plan.Changes) != 1 || plan.Changes[0].LogicalResourceID != "Topic" {
+				t.Errorf("Plan() changes = %+v, want added Topic", plan.Changes)
+			}
+
+			if plan.EstimatedCostURL != tt.wantCostURL {
+				t.Errorf("Plan() cost URL = %q, want %q", plan.EstimatedCostURL, tt.wantCostURL)
+			}
+
+			if *client.executed {
+				t.Error("Plan() must not execute the ChangeSet")
+			}
+		})
+	}
+}
+
+func TestCloudformation_Apply(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(m mockPlanClient, c *Cloudformation, p *DeploymentPlan)
+		wantStale bool
+	}{
+		{name: "Test unchanged plan is applied", modify: func(mockPlanClient, *Cloudformation, *DeploymentPlan) {}},
+		{name: "Test deleted change set", modify: func(m mockPlanClient, _ *Cloudformation, _ *DeploymentPlan) {
+			*m.deleted = true
+		}, wantStale: true},
+		{name: "Test updated stack", modify: func(m mockPlanClient, _ *Cloudformation, _ *DeploymentPlan) {
+			m.stack.LastUpdatedTime = aws.Time(time.Now())
+		}, wantStale: true},
+		{name: "Test changed stack status", modify: func(m mockPlanClient, _ *Cloudformation, _ *DeploymentPlan) {
+			m.stack.StackStatus = aws.String(cloudformation.StackStatusUpdateRollbackComplete)
+		}, wantStale: true},
+		{name: "Test expired plan", modify: func(_ mockPlanClient, c *Cloudformation, p *DeploymentPlan) {
+			c.MaxPlanAge = time.Hour
+			p.CreatedAt = time.Now().Add(-2 * time.Hour)
+		}, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", ""))
+			c := &Cloudformation{CFClient: client, StackName: "test"}
+
+			plan, err := c.Plan(hashTemplate, false)
+			if err != nil {
+				t.Fatalf("Plan() unexpected error = %v", err)
+			}
+
+			filename := filepath.Join(t.TempDir(), "plan.json")
+			if err = plan.Save(filename); err != nil {
+				t.Fatalf("Save() unexpected error = %v", err)
+			}
+
+			if plan, err = LoadPlan(filename); err != nil {
+				t.Fatalf("LoadPlan() unexpected error = %v", err)
+			}
+
+			if plan.TemplateHash == "" || plan.ChangeSetType != "UPDATE" || len(plan.Changes) != 1 {
+				t.Errorf("LoadPlan() = %+v", plan)
+			}
+
+			tt.modify(client, c, plan)
+
+			err = c.Apply(plan)
+			if tt.wantStale != errors.Is(err, ErrStalePlan) || (!tt.wantStale && err != nil) {
+				t.Errorf("Apply() error = %v, want stale %v", err, tt.wantStale)
+			}
+
+			if *client.executed == tt.wantStale {
+				t.Errorf("ChangeSet executed = %v, want %v", *client.executed, !tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestDeploymentPlan_Save_redactsNoEcho(t *testing.T) {
+	c := &Cloudformation{
+		CFClient:  newMockPlanClient(resourceChange(cloudformation.ChangeActionAdd, "Topic", "")),
+		StackName: "test",
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+			{ParameterKey: aws.String("Password"), ParameterValue: aws.String("secret")},
+		},
+	}
+
+	plan, err := c.Plan(journalTemplate, false)
+	if err != nil {
+		t.Fatalf("Plan() unexpected error = %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "plan.json")
+	if err = plan.Save(filename); err != nil {
+		t.Fatalf("Save() unexpected error = %v", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "secret") || !strings.Contains(string(b), "prod") {
+		t.Errorf("Save() wrote %s, want the NoEcho value redacted", b)
+	}
+}
@@ -0,0 +1,142 @@
+package godeploycfn
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+var (
+	// ErrNoJournal is returned by PreviousDeployment if no Journal is set.
+	ErrNoJournal = errors.New("no journal configured")
+	// ErrNoPreviousDeployment is returned by PreviousDeployment if the journal contains no earlier version.
+	ErrNoPreviousDeployment = errors.New("no previous deployment found")
+)
+
+// RedeployMetadataKey is added to the JournalMetadata of a redeployment, its value is the redeployed TemplateHash.
+const RedeployMetadataKey = "redeployOf"
+
+// PreviousDeployment returns the latest successful deployment in the Journal whose template or configuration
+// differs from the one deployed last. Records without template, e.g. of applied plans, are never returned.
+func (c *Cloudformation) PreviousDeployment() (*DeploymentRecord, error) {
+	if c.Journal == nil {
+		return nil, ErrNoJournal
+	}
+
+	history, err := c.Journal.History(c.StackName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading deployment history: %w", err)
+	}
+
+	current := ""
+
+	for i := len(history) - 1; i >= 0; i-- {
+		record := history[i]
+		if record.Outcome != DeploymentSucceeded {
+			continue
+		}
+
+		if current == "" {
+			current = record.TemplateHash
+
+			continue
+		}
+
+		if record.TemplateHash != current && record.TemplateBody != "" {
+			return record, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w for stack %s", ErrNoPreviousDeployment, c.StackName)
+}
+
+// SnapshotDeployment returns a record of the deployed template and parameters, retrieved with GetTemplate.
+// It can be saved before a deployment and passed to Redeploy to roll back without a Journal.
+// Values of NoEcho parameters are redacted.
+func (c *Cloudformation) SnapshotDeployment() (*DeploymentRecord, error) {
+	stack, err := c.describeStack()
+	if err != nil {
+		return nil, err
+	}
+
+	if stack == nil {
+		return nil, fmt.Errorf("stack %s does not exist", c.StackName)
+	}
+
+	body, err := c.deployedTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &DeploymentRecord{
+		StackName:    c.StackName,
+		TemplateBody: body,
+		Parameters:   redactParameters(body, stack.Parameters),
+		Outcome:      DeploymentSucceeded,
+	}
+
+	for _, capability := range stack.Capabilities {
+		if aws.StringValue(capability) == cloudformation.CapabilityCapabilityNamedIam {
+			record.NamedIAM = true
+		}
+	}
+
+	snapshot := *c
+	snapshot.Parameters = record.Parameters
+
+	if record.TemplateHash, err = snapshot.DeploymentHash(body, record.NamedIAM); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Redeploy deploys the template and parameters of the record, e.g. from PreviousDeployment or
+// SnapshotDeployment, with CloudFormationDeploy. The tags and other options of c are kept.
+// Redacted parameters keep their deployed value. The diff to the deployed template is logged before
+// the deployment and returned.
+func (c *Cloudformation) Redeploy(record *DeploymentRecord) (*TemplateDiff, error) {
+	if record.TemplateBody == "" {
+		return nil, fmt.Errorf("deployment record of stack %s has no template", record.StackName)
+	}
+
+	diff, err := c.DiffTemplate(record.TemplateBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if diff.IsEmpty() {
+		c.logger().Infof("Redeploying template %s, the template is unchanged.", record.TemplateHash)
+	} else {
+		c.logger().Infof("Redeploying template %s with the following changes:\n%s", record.TemplateHash, diff)
+	}
+
+	redeploy := *c
+	redeploy.Parameters = restoreParameters(record.Parameters)
+	redeploy.JournalMetadata = map[string]string{RedeployMetadataKey: record.TemplateHash}
+
+	for k, v := range c.JournalMetadata {
+		redeploy.JournalMetadata[k] = v
+	}
+
+	return diff, redeploy.CloudFormationDeploy(record.TemplateBody, record.NamedIAM)
+}
+
+// restoreParameters replaces redacted parameter values with UsePreviousValue.
+func restoreParameters(parameters []*cloudformation.Parameter) []*cloudformation.Parameter {
+	restored := make([]*cloudformation.Parameter, 0, len(parameters))
+
+	for _, p := range parameters {
+		p := *p
+		if aws.StringValue(p.ParameterValue) == redactedValue {
+			p.ParameterValue = nil
+			p.UsePreviousValue = aws.Bool(true)
+		}
+
+		restored = append(restored, &p)
+	}
+
+	return restored
+}
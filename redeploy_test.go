@@ -0,0 +1,137 @@
+package godeploycfn
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+type mockRedeployClient struct {
+	mockChangeSetListClient
+	created *cloudformation.CreateChangeSetInput
+}
+
+func (m mockRedeployClient) GetTemplate(*cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	return &cloudformation.GetTemplateOutput{TemplateBody: aws.String(hashTemplate)}, nil
+}
+
+func (m mockRedeployClient) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	*m.created = *input
+
+	return m.mockChangeSetListClient.CreateChangeSet(input)
+}
+
+func newMockRedeployClient() mockRedeployClient {
+	return mockRedeployClient{mockChangeSetListClient: newMockChangeSetListClient(), created: &cloudformation.CreateChangeSetInput{}}
+}
+
+func TestCloudformation_PreviousDeployment(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*DeploymentRecord
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Test previous successful template",
+			records: []*DeploymentRecord{
+				{TemplateHash: "v1", Outcome: DeploymentSucceeded},
+				{TemplateHash: "v2", Outcome: DeploymentSucceeded},
+				{TemplateHash: "v3", Outcome: DeploymentFailed},
+				{TemplateHash: "v2", Outcome: DeploymentSucceeded},
+			},
+			want: "v1",
+		},
+		{
+			name:    "Test single version",
+			records: []*DeploymentRecord{{TemplateHash: "v1", Outcome: DeploymentSucceeded}},
+			wantErr: ErrNoPreviousDeployment,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+			for _, r := range tt.records {
+				r.StackName = "test"
+				r.TemplateBody = journalTemplate
+
+				if err := journal.Record(r); err != nil {
+					t.Fatalf("Record() unexpected error = %v", err)
+				}
+			}
+
+			c := &Cloudformation{StackName: "test", Journal: journal}
+
+			got, err := c.PreviousDeployment()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PreviousDeployment() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && got.TemplateHash != tt.want {
+				t.Errorf("PreviousDeployment() = %s, want %s", got.TemplateHash, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&Cloudformation{StackName: "test"}).PreviousDeployment(); !errors.Is(err, ErrNoJournal) {
+		t.Errorf("PreviousDeployment() without journal error = %v, want %v", err, ErrNoJournal)
+	}
+}
+
+func TestCloudformation_Redeploy(t *testing.T) {
+	client := newMockRedeployClient()
+	journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	c := &Cloudformation{CFClient: client, StackName: "test", Journal: journal}
+
+	record := &DeploymentRecord{
+		StackName:    "test",
+		TemplateHash: "v1",
+		TemplateBody: journalTemplate,
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+			{ParameterKey: aws.String("Password"), ParameterValue: aws.String(redactedValue)},
+		},
+	}
+
+	diff, err := c.Redeploy(record)
+	if err != nil {
+		t.Fatalf("Redeploy() unexpected error = %v", err)
+	}
+
+	if diff.IsEmpty() || !*client.executed {
+		t.Errorf("Redeploy() diff = %v, executed = %v", diff, *client.executed)
+	}
+
+	params := client.created.Parameters
+	if len(params) != 2 || aws.StringValue(params[0].ParameterValue) != "prod" ||
+		params[1].ParameterValue != nil || !aws.BoolValue(params[1].UsePreviousValue) {
+		t.Errorf("CreateChangeSet() parameters = %v", params)
+	}
+
+	history, err := journal.History("test")
+	if err != nil || len(history) != 1 || history[0].Metadata[RedeployMetadataKey] != "v1" {
+		t.Errorf("History() = %v, %v, want a redeployment of v1", history, err)
+	}
+}
+
+func TestCloudformation_SnapshotDeployment(t *testing.T) {
+	client := newMockRedeployClient()
+	client.stack.Parameters = []*cloudformation.Parameter{{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")}}
+	client.stack.Capabilities = []*string{aws.String(cloudformation.CapabilityCapabilityNamedIam)}
+
+	c := &Cloudformation{CFClient: client, StackName: "test"}
+
+	got, err := c.SnapshotDeployment()
+	if err != nil {
+		t.Fatalf("SnapshotDeployment() unexpected error = %v", err)
+	}
+
+	if got.TemplateBody != hashTemplate || !got.NamedIAM || got.TemplateHash == "" ||
+		len(got.Parameters) != 1 || aws.StringValue(got.Parameters[0].ParameterValue) != "prod" {
+		t.Errorf("SnapshotDeployment() = %+v", got)
+	}
+}
@@ -0,0 +1,315 @@
+package godeploycfn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// ChangeSetRenderer writes a human-readable representation of a ChangeSet.
+type ChangeSetRenderer interface {
+	Render(w io.Writer, changeSet *cloudformation.DescribeChangeSetOutput) error
+}
+
+// ChangeSetSummary is a flattened view of a described ChangeSet.
+type ChangeSetSummary struct {
+	StackName     string                  `json:"stackName"`
+	ChangeSetName string                  `json:"changeSetName"`
+	ChangeSetID   string                  `json:"changeSetId,omitempty"`
+	Status        string                  `json:"status,omitempty"`
+	Changes       []ResourceChangeSummary `json:"changes"`
+}
+
+// ResourceChangeSummary describes the change of a single resource.
+type ResourceChangeSummary struct {
+	Action             string                `json:"action"`
+	LogicalResourceID  string                `json:"logicalResourceId"`
+	PhysicalResourceID string                `json:"physicalResourceId,omitempty"`
+	ResourceType       string                `json:"resourceType"`
+	Replacement        string                `json:"replacement,omitempty"`
+	Scope              []string              `json:"scope,omitempty"`
+	Details            []ChangeDetailSummary `json:"details,omitempty"`
+}
+
+// ChangeDetailSummary describes why a resource changes.
+type ChangeDetailSummary struct {
+	Attribute          string `json:"attribute,omitempty"`
+	Name               string `json:"name,omitempty"`
+	RequiresRecreation string `json:"requiresRecreation,omitempty"`
+	ChangeSource       string `json:"changeSource,omitempty"`
+	CausingEntity      string `json:"causingEntity,omitempty"`
+	Evaluation         string `json:"evaluation,omitempty"`
+}
+
+// IsReplacement reports whether the resource is definitely replaced.
+func (r *ResourceChangeSummary) IsReplacement() bool {
+	return r.Replacement == cloudformation.ReplacementTrue
+}
+
+// IsRemoval reports whether the resource is removed.
+func (r *ResourceChangeSummary) IsRemoval() bool {
+	return r.Action == cloudformation.ChangeActionRemove
+}
+
+// SummarizeChangeSet flattens the given ChangeSet.
+func SummarizeChangeSet(o *cloudformation.DescribeChangeSetOutput) ChangeSetSummary {
+	summary := ChangeSetSummary{
+		StackName:     aws.StringValue(o.StackName),
+		ChangeSetName: aws.StringValue(o.ChangeSetName),
+		ChangeSetID:   aws.StringValue(o.ChangeSetId),
+		Status:        aws.StringValue(o.Status),
+		Changes:       []ResourceChangeSummary{},
+	}
+
+	for _, change := range o.Changes {
+		rc := change.ResourceChange
+		if rc == nil {
+			continue
+		}
+
+		rcs := ResourceChangeSummary{
+			Action:             aws.StringValue(rc.Action),
+			LogicalResourceID:  aws.StringValue(rc.LogicalResourceId),
+			PhysicalResourceID: aws.StringValue(rc.PhysicalResourceId),
+			ResourceType:       aws.StringValue(rc.ResourceType),
+			Replacement:        aws.StringValue(rc.Replacement),
+			Scope:              aws.StringValueSlice(rc.Scope),
+		}
+
+		for _, d := range rc.Details {
+			cds := ChangeDetailSummary{
+				ChangeSource:  aws.StringValue(d.ChangeSource),
+				CausingEntity: aws.StringValue(d.CausingEntity),
+				Evaluation:    aws.StringValue(d.Evaluation),
+			}
+			if d.Target != nil {
+				cds.Attribute = aws.StringValue(d.Target.Attribute)
+				cds.Name = aws.StringValue(d.Target.Name)
+				cds.RequiresRecreation = aws.StringValue(d.Target.RequiresRecreation)
+			}
+
+			rcs.Details = append(rcs.Details, cds)
+		}
+
+		summary.Changes = append(summary.Changes, rcs)
+	}
+
+	return summary
+}
+
+// JSONRenderer renders a ChangeSet as indented ChangeSetSummary JSON.
+type JSONRenderer struct{}
+
+// Render writes the ChangeSet as JSON.
+func (JSONRenderer) Render(w io.Writer, changeSet *cloudformation.DescribeChangeSetOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(SummarizeChangeSet(changeSet)); err != nil {
+		return fmt.Errorf("error encoding ChangeSet as JSON: %w", err)
+	}
+
+	return nil
+}
+
+// TableRenderer renders a ChangeSet as a plain text table for terminals.
+type TableRenderer struct {
+	// Color highlights additions, modifications, removals and replacements with ANSI colors.
+	Color bool
+}
+
+// Render writes the ChangeSet as table.
+func (t TableRenderer) Render(w io.Writer, changeSet *cloudformation.DescribeChangeSetOutput) error {
+	summary := SummarizeChangeSet(changeSet)
+
+	rows := [][]string{{"ACTION", "LOGICAL ID", "PHYSICAL ID", "TYPE", "REPLACEMENT"}}
+	for _, rc := range summary.Changes {
+		rows = append(rows, []string{rc.Action, rc.LogicalResourceID, rc.PhysicalResourceID, rc.ResourceType, rc.Replacement})
+	}
+
+	widths := make([]int, len(rows[0]))
+
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "ChangeSet %s for stack %s\n", summary.ChangeSetName, summary.StackName)
+
+	for r, row := range rows {
+		for i, cell := range row {
+			padded := cell + strings.Repeat(" ", widths[i]-len(cell))
+			if i < len(row)-1 {
+				padded += "  "
+			}
+
+			if r > 0 {
+				padded = t.colorize(padded, i, summary.Changes[r-1])
+			}
+
+			sb.WriteString(padded)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	if len(summary.Changes) == 0 {
+		sb.WriteString("(no changes)\n")
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error writing ChangeSet table: %w", err)
+	}
+
+	return nil
+}
+
+func (t TableRenderer) colorize(cell string, column int, rc ResourceChangeSummary) string {
+	if !t.Color {
+		return cell
+	}
+
+	var color string
+
+	switch {
+	case column == 0 && rc.Action == cloudformation.ChangeActionAdd:
+		color = ansiGreen
+	case column == 0 && rc.Action == cloudformation.ChangeActionModify:
+		color = ansiYellow
+	case column == 0 && rc.IsRemoval():
+		color = ansiRed
+	case column == 4 && rc.IsReplacement():
+		color = ansiRed
+	case column == 4 && rc.Replacement == cloudformation.ReplacementConditional:
+		color = ansiYellow
+	default:
+		return cell
+	}
+
+	return color + cell + ansiReset
+}
+
+// MarkdownRenderer renders a ChangeSet as GitHub-flavored Markdown, e.g. for pull request comments.
+type MarkdownRenderer struct{}
+
+// Render writes the ChangeSet as Markdown.
+func (MarkdownRenderer) Render(w io.Writer, changeSet *cloudformation.DescribeChangeSetOutput) error {
+	summary := SummarizeChangeSet(changeSet)
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "### ChangeSet `%s` for stack `%s`\n\n", summary.ChangeSetName, summary.StackName)
+
+	if len(summary.Changes) == 0 {
+		sb.WriteString("No changes.\n")
+	} else {
+		writeMarkdownWarnings(&sb, summary.Changes)
+		writeMarkdownTable(&sb, summary.Changes)
+		writeMarkdownDetails(&sb, summary.Changes)
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error writing ChangeSet markdown: %w", err)
+	}
+
+	return nil
+}
+
+func writeMarkdownWarnings(sb *strings.Builder, changes []ResourceChangeSummary) {
+	var warnings []string
+
+	for _, rc := range changes {
+		switch {
+		case rc.IsRemoval():
+			warnings = append(warnings, fmt.Sprintf("`%s` (`%s`) will be **removed**.", rc.LogicalResourceID, rc.ResourceType))
+		case rc.IsReplacement():
+			warnings = append(warnings, fmt.Sprintf("`%s` (`%s`) will be **replaced**.", rc.LogicalResourceID, rc.ResourceType))
+		}
+	}
+
+	if len(warnings) == 0 {
+		return
+	}
+
+	sb.WriteString("> [!WARNING]\n")
+
+	for _, warning := range warnings {
+		fmt.Fprintf(sb, "> - %s\n", warning)
+	}
+
+	sb.WriteString("\n")
+}
+
+func writeMarkdownTable(sb *strings.Builder, changes []ResourceChangeSummary) {
+	sb.WriteString("| Action | Logical ID | Physical ID | Type | Replacement |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+
+	for _, rc := range changes {
+		fmt.Fprintf(sb, "| %s %s | `%s` | %s | `%s` | %s |\n",
+			markdownActionIcon(rc), rc.Action, rc.LogicalResourceID,
+			markdownCode(rc.PhysicalResourceID), rc.ResourceType, escapeMarkdownCell(rc.Replacement))
+	}
+
+	sb.WriteString("\n")
+}
+
+func writeMarkdownDetails(sb *strings.Builder, changes []ResourceChangeSummary) {
+	for _, rc := range changes {
+		if len(rc.Details) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(sb, "<details>\n<summary><code>%s</code> (%s)</summary>\n\n", rc.LogicalResourceID, rc.Action)
+		sb.WriteString("| Attribute | Name | Requires recreation | Change source | Causing entity |\n")
+		sb.WriteString("| --- | --- | --- | --- | --- |\n")
+
+		for _, d := range rc.Details {
+			fmt.Fprintf(sb, "| %s | %s | %s | %s | %s |\n",
+				escapeMarkdownCell(d.Attribute), markdownCode(d.Name), escapeMarkdownCell(d.RequiresRecreation),
+				escapeMarkdownCell(d.ChangeSource), markdownCode(d.CausingEntity))
+		}
+
+		sb.WriteString("\n</details>\n\n")
+	}
+}
+
+func markdownActionIcon(rc ResourceChangeSummary) string {
+	switch {
+	case rc.IsRemoval():
+		return ":x:"
+	case rc.IsReplacement():
+		return ":warning:"
+	case rc.Action == cloudformation.ChangeActionAdd:
+		return ":heavy_plus_sign:"
+	}
+
+	return ":pencil2:"
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return "`" + escapeMarkdownCell(s) + "`"
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
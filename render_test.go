@@ -0,0 +1,112 @@
+package godeploycfn
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testChangeSet() *cloudformation.DescribeChangeSetOutput {
+	modify := resourceChange(cloudformation.ChangeActionModify, "Table", cloudformation.ReplacementTrue)
+	modify.ResourceChange.ResourceType = aws.String("AWS::DynamoDB::Table")
+	modify.ResourceChange.Details = []*cloudformation.ResourceChangeDetail{
+		{
+			ChangeSource: aws.String(cloudformation.ChangeSourceDirectModification),
+			Evaluation:   aws.String(cloudformation.EvaluationTypeStatic),
+			Target: &cloudformation.ResourceTargetDefinition{
+				Attribute:          aws.String(cloudformation.ResourceAttributeProperties),
+				Name:               aws.String("KeySchema"),
+				RequiresRecreation: aws.String(cloudformation.RequiresRecreationAlways),
+			},
+		},
+	}
+
+	return &cloudformation.DescribeChangeSetOutput{
+		ChangeSetName: aws.String("cs"),
+		StackName:     aws.String("stack"),
+		Changes: []*cloudformation.Change{
+			resourceChange(cloudformation.ChangeActionAdd, "Topic", ""),
+			modify,
+			resourceChange(cloudformation.ChangeActionRemove, "Queue", ""),
+		},
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer ChangeSetRenderer
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Test plain table",
+			renderer: TableRenderer{},
+			contains: []string{"ACTION", "Topic", "AWS::DynamoDB::Table", "Remove"},
+			excludes: []string{ansiRed},
+		},
+		{
+			name:     "Test colored table",
+			renderer: TableRenderer{Color: true},
+			contains: []string{ansiGreen + "Add", ansiRed + "True", ansiRed + "Remove"},
+		},
+		{
+			name:     "Test markdown",
+			renderer: MarkdownRenderer{},
+			contains: []string{
+				"> - `Table` (`AWS::DynamoDB::Table`) will be **replaced**.",
+				"> - `Queue` (`AWS::SNS::Topic`) will be **removed**.",
+				"<summary><code>Table</code> (Modify)</summary>",
+				"| Properties | `KeySchema` | Always | DirectModification |  |",
+			},
+			excludes: []string{"<code>Topic</code>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := tt.renderer.Render(&buf, testChangeSet()); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+
+			for _, want := range tt.contains {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Render() output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(buf.String(), unwanted) {
+					t.Errorf("Render() output unexpectedly contains %q:\n%s", unwanted, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestJSONRenderer_Render(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := (JSONRenderer{}).Render(&buf, testChangeSet()); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+
+	var got ChangeSetSummary
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+
+	if len(got.Changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(got.Changes))
+	}
+
+	if !got.Changes[1].IsReplacement() || got.Changes[1].Details[0].Name != "KeySchema" {
+		t.Errorf("unexpected second change: %+v", got.Changes[1])
+	}
+}
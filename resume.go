@@ -0,0 +1,152 @@
+package godeploycfn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/uuid"
+)
+
+// changeSetNamespace is the namespace of the UUIDs of ChangeSets created for a DeploymentID.
+var changeSetNamespace = uuid.MustParse("4f0b8a2e-5d8c-4c1e-9a57-6f3d2b8e1c90")
+
+// changeSetID returns the UUID of the ChangeSet name. It's derived from the stack name, the DeploymentHash and the
+// DeploymentID if one is set, so a retried deployment attempt creates the same ChangeSet.
+func (c *Cloudformation) changeSetID(hash string) (uuid.UUID, error) {
+	if c.DeploymentID != "" {
+		return uuid.NewSHA1(changeSetNamespace, []byte(c.StackName+"\n"+hash+"\n"+c.DeploymentID)), nil
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return id, fmt.Errorf("error while generating UUID %w", err)
+	}
+
+	return id, nil
+}
+
+// requestToken returns the idempotency token of requests for the ChangeSet, which may be its name or ARN.
+func requestToken(changeSetName string) string {
+	sum := sha256.Sum256([]byte(changeSetName))
+
+	return "go-deploy-cfn-" + hex.EncodeToString(sum[:16])
+}
+
+// Kinds of ChangeSets created by this library, they are part of the ChangeSet description.
+const (
+	// changeSetKindDeployment is executed right after its creation.
+	changeSetKindDeployment = "deployment"
+	// changeSetKindApproval is executed after an Approver approved it, it may be deferred.
+	changeSetKindApproval = "approval"
+	// changeSetKindPlan is created by Plan and executed later by Apply.
+	changeSetKindPlan = "plan"
+)
+
+// changeSetDescriptionPrefix starts the descriptions of ChangeSets created by this library.
+const changeSetDescriptionPrefix = "go-deploy-cfn "
+
+// changeSetDescription returns the description of ChangeSets created by this library, it identifies the kind of
+// the ChangeSet and the deployed template and configuration.
+func changeSetDescription(kind, hash string) string {
+	return changeSetDescriptionPrefix + kind + " " + hash
+}
+
+// parseChangeSetDescription returns the kind and hash of a ChangeSet description, which are empty if it wasn't
+// created by changeSetDescription.
+func parseChangeSetDescription(description string) (kind, hash string) {
+	fields := strings.Fields(strings.TrimPrefix(description, changeSetDescriptionPrefix))
+	if !strings.HasPrefix(description, changeSetDescriptionPrefix) || len(fields) != 2 {
+		return "", ""
+	}
+
+	return fields[0], fields[1]
+}
+
+// deploymentKind returns the kind of the ChangeSets created by CloudFormationDeploy.
+func (c *Cloudformation) deploymentKind() string {
+	if c.Approver != nil {
+		return changeSetKindApproval
+	}
+
+	return changeSetKindDeployment
+}
+
+// resumeDeployment waits for the execution of a ChangeSet for the same DeploymentHash which has been started
+// earlier, e.g. by a deployment which got interrupted. It reports whether such a ChangeSet was found, and adds
+// it to the record if that isn't nil. The stack policy is restored even if the execution failed.
+func (c *Cloudformation) resumeDeployment(templateBody string, namedIAM bool, record *DeploymentRecord) (bool, error) {
+	hash, err := c.DeploymentHash(templateBody, namedIAM)
+	if err != nil {
+		return false, err
+	}
+
+	summary, err := c.executingChangeSet(hash)
+	if err != nil || summary == nil {
+		return false, err
+	}
+
+	if record != nil {
+		record.ChangeSetID = aws.StringValue(summary.ChangeSetId)
+	}
+
+	name := aws.StringValue(summary.ChangeSetName)
+	c.logger().Infof("Resuming the deployment of ChangeSet '%s' which is already being executed.", name)
+
+	errWait := c.waitForChangeSetExecution(name)
+
+	if err = c.restoreStackPolicyAfterResume(); err != nil {
+		if errWait != nil {
+			return true, fmt.Errorf("%w (restoring the stack policy failed as well: %v)", errWait, err)
+		}
+
+		return true, err
+	}
+
+	if errWait != nil {
+		return true, errWait
+	}
+
+	return true, c.syncTerminationProtection()
+}
+
+// changeSetHash returns the DeploymentHash in the description of the ChangeSet.
+func changeSetHash(summary *cloudformation.ChangeSetSummary) string {
+	_, hash := parseChangeSetDescription(aws.StringValue(summary.Description))
+
+	return hash
+}
+
+// executingChangeSet returns the ChangeSet created by this library for the DeploymentHash which is being executed,
+// or nil if there is none.
+func (c *Cloudformation) executingChangeSet(hash string) (*cloudformation.ChangeSetSummary, error) {
+	input := &cloudformation.ListChangeSetsInput{StackName: aws.String(c.StackName)}
+
+	for {
+		out, err := c.CFClient.ListChangeSets(input)
+		if err != nil {
+			if strings.Contains(err.Error(), "does not exist") {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("error listing change sets: %w", err)
+		}
+
+		for _, summary := range out.Summaries {
+			if c.isOwnChangeSet(aws.StringValue(summary.ChangeSetName)) &&
+				aws.StringValue(summary.ExecutionStatus) == cloudformation.ExecutionStatusExecuteInProgress &&
+				changeSetHash(summary) == hash {
+				return summary, nil
+			}
+		}
+
+		if out.NextToken == nil {
+			return nil, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
@@ -0,0 +1,146 @@
+package godeploycfn
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestCloudformation_changeSetID(t *testing.T) {
+	c := &Cloudformation{StackName: "test", DeploymentID: "pipeline-42"}
+
+	first, err := c.changeSetID("hash")
+	if err != nil {
+		t.Fatalf("changeSetID() unexpected error = %v", err)
+	}
+
+	second, _ := c.changeSetID("hash")
+	otherHash, _ := c.changeSetID("other")
+	c.DeploymentID = "pipeline-43"
+	otherAttempt, _ := c.changeSetID("hash")
+
+	if first != second {
+		t.Errorf("changeSetID() = %s and %s, want the same ID for the same attempt", first, second)
+	}
+
+	if first == otherHash || first == otherAttempt {
+		t.Errorf("changeSetID() = %s, want a different ID for a different hash or attempt", first)
+	}
+
+	if name := fmt.Sprintf("test-%s", first); !c.isOwnChangeSet(name) {
+		t.Errorf("isOwnChangeSet(%s) = false", name)
+	}
+
+	if requestToken(fmt.Sprintf("test-%s", first)) != requestToken(fmt.Sprintf("test-%s", second)) {
+		t.Error("requestToken() differs for the same ChangeSet")
+	}
+}
+
+func TestCloudformation_CloudFormationDeploy_resume(t *testing.T) {
+	tests := []struct {
+		name         string
+		description  func(hash string) string
+		wantExecuted bool
+	}{
+		{name: "Test executing change set is resumed", description: func(hash string) string {
+			return changeSetDescription(changeSetKindDeployment, hash)
+		}},
+		{name: "Test change set of another template is ignored", description: func(string) string {
+			return changeSetDescription(changeSetKindDeployment, "other")
+		}, wantExecuted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockChangeSetListClient()
+			c := &Cloudformation{CFClient: client, StackName: "test", Resume: true}
+
+			hash, err := c.DeploymentHash(hashTemplate, false)
+			if err != nil {
+				t.Fatalf("DeploymentHash() unexpected error = %v", err)
+			}
+
+			executing := changeSetSummary(ownChangeSet+"5", cloudformation.ChangeSetStatusCreateComplete,
+				cloudformation.ExecutionStatusExecuteInProgress, time.Minute)
+			executing.Description = aws.String(tt.description(hash))
+			client.pages = [][]*cloudformation.ChangeSetSummary{{executing}}
+			c.CFClient = client
+
+			if err = c.CloudFormationDeploy(hashTemplate, false); err != nil {
+				t.Fatalf("CloudFormationDeploy() unexpected error = %v", err)
+			}
+
+			if *client.executed != tt.wantExecuted {
+				t.Errorf("ChangeSet executed = %v, want %v", *client.executed, tt.wantExecuted)
+			}
+		})
+	}
+}
+
+type mockResumeStackPolicyClient struct {
+	mockChangeSetListClient
+	policy  *string
+	setCall *[]string
+}
+
+func (m mockResumeStackPolicyClient) GetStackPolicy(*cloudformation.GetStackPolicyInput) (*cloudformation.GetStackPolicyOutput, error) {
+	return &cloudformation.GetStackPolicyOutput{StackPolicyBody: aws.String(*m.policy)}, nil
+}
+
+func (m mockResumeStackPolicyClient) SetStackPolicy(input *cloudformation.SetStackPolicyInput) (*cloudformation.SetStackPolicyOutput, error) {
+	*m.policy = *input.StackPolicyBody
+	*m.setCall = append(*m.setCall, *input.StackPolicyBody)
+
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
+
+func TestCloudformation_CloudFormationDeploy_resumeStackPolicyOverride(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		wantPolicy string
+	}{
+		{name: "Test override is replaced without stack policy", wantPolicy: allowAllStackPolicy},
+		{name: "Test stack policy is restored", policy: denyReplacePolicy, wantPolicy: denyReplacePolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockResumeStackPolicyClient{
+				mockChangeSetListClient: newMockChangeSetListClient(),
+				policy:                  aws.String(overridePolicy),
+				setCall:                 &[]string{},
+			}
+			c := &Cloudformation{
+				CFClient:                 client,
+				StackName:                "test",
+				Resume:                   true,
+				StackPolicy:              tt.policy,
+				StackPolicyDuringUpdate:  overridePolicy,
+				AllowStackPolicyOverride: true,
+			}
+
+			hash, err := c.DeploymentHash(hashTemplate, false)
+			if err != nil {
+				t.Fatalf("DeploymentHash() unexpected error = %v", err)
+			}
+
+			executing := changeSetSummary(ownChangeSet+"5", cloudformation.ChangeSetStatusCreateComplete,
+				cloudformation.ExecutionStatusExecuteInProgress, time.Minute)
+			executing.Description = aws.String(changeSetDescription(changeSetKindDeployment, hash))
+			client.pages = [][]*cloudformation.ChangeSetSummary{{executing}}
+			c.CFClient = client
+
+			if err = c.CloudFormationDeploy(hashTemplate, false); err != nil {
+				t.Fatalf("CloudFormationDeploy() unexpected error = %v", err)
+			}
+
+			if *client.executed || len(*client.setCall) != 1 || *client.policy != tt.wantPolicy {
+				t.Errorf("SetStackPolicy calls = %v, want %s", *client.setCall, tt.wantPolicy)
+			}
+		})
+	}
+}
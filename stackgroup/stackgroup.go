@@ -0,0 +1,328 @@
+// Package stackgroup deploys a collection of resources which may exceed the resource limit of a single stack
+// to several stacks named <name>-1, <name>-2, ...
+//
+// Resources stay in the stack they are deployed in as long as they are part of the collection, new resources
+// are put into the first stack with free capacity. Stacks which don't hold any resources anymore are deleted.
+// Resources must not depend on each other, as they might end up in different stacks.
+package stackgroup
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/sirupsen/logrus"
+
+	godeploycfn "github.com/moia-oss/go-deploy-cfn"
+	"github.com/moia-oss/go-deploy-cfn/lint"
+	"github.com/moia-oss/go-deploy-cfn/template"
+)
+
+// MaxResourcesPerStack is the CloudFormation limit of resources in a single stack.
+const MaxResourcesPerStack = 500
+
+// Group deploys a template to as many stacks as needed.
+type Group struct {
+	CFClient    cloudformationiface.CloudFormationAPI
+	Name        string
+	LogrusEntry *logrus.Entry
+	// MaxResourcesPerStack defaults to MaxResourcesPerStack.
+	MaxResourcesPerStack int
+	// Configure is called for the Cloudformation of every stack before it gets deployed, e.g. to set an Approver.
+	Configure func(c *godeploycfn.Cloudformation)
+}
+
+// Plan is the assignment of resources to stacks.
+type Plan struct {
+	// Stacks maps stack names to the sorted logical IDs of their resources.
+	Stacks map[string][]string
+	// Obsolete are the stacks which don't hold any resources anymore.
+	Obsolete []string
+}
+
+// StackNames returns the names of all stacks of the plan in order.
+func (p *Plan) StackNames() []string {
+	names := make([]string, 0, len(p.Stacks))
+	for name := range p.Stacks {
+		names = append(names, name)
+	}
+
+	sortStackNames(names)
+
+	return names
+}
+
+func (g *Group) logger() *logrus.Entry {
+	fields := logrus.Fields{
+		"stack_group": g.Name,
+	}
+
+	if g.LogrusEntry == nil {
+		return logrus.WithFields(fields)
+	}
+
+	return g.LogrusEntry.WithFields(fields)
+}
+
+func (g *Group) maxResources() int {
+	if g.MaxResourcesPerStack <= 0 {
+		return MaxResourcesPerStack
+	}
+
+	return g.MaxResourcesPerStack
+}
+
+func (g *Group) stackName(n int) string {
+	return fmt.Sprintf("%s-%d", g.Name, n)
+}
+
+func (g *Group) stackNumber(stackName string) (int, bool) {
+	m := regexp.MustCompile(`^` + regexp.QuoteMeta(g.Name) + `-(\d+)$`).FindStringSubmatch(stackName)
+	if m == nil {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(m[1])
+
+	return n, err == nil && n > 0
+}
+
+// Assign distributes logicalIDs to stacks. Resources found in existing (stack name to logical IDs) stay where
+// they are, new resources fill up the stacks in order, and new stacks are only added when all are full.
+func (g *Group) Assign(existing map[string][]string, logicalIDs []string) *Plan {
+	wanted := map[string]bool{}
+	for _, id := range logicalIDs {
+		wanted[id] = true
+	}
+
+	stacks := map[int][]string{}
+	placed := map[string]bool{}
+
+	for name, ids := range existing {
+		n, ok := g.stackNumber(name)
+		if !ok {
+			continue
+		}
+
+		stacks[n] = nil
+
+		for _, id := range ids {
+			if wanted[id] && !placed[id] {
+				stacks[n] = append(stacks[n], id)
+				placed[id] = true
+			}
+		}
+	}
+
+	sortedIDs := append([]string{}, logicalIDs...)
+	sort.Strings(sortedIDs)
+
+	n := 1
+
+	for _, id := range sortedIDs {
+		if placed[id] {
+			continue
+		}
+
+		for len(stacks[n]) >= g.maxResources() {
+			n++
+		}
+
+		stacks[n] = append(stacks[n], id)
+		placed[id] = true
+	}
+
+	plan := &Plan{Stacks: map[string][]string{}}
+
+	for n, ids := range stacks {
+		if len(ids) == 0 {
+			plan.Obsolete = append(plan.Obsolete, g.stackName(n))
+
+			continue
+		}
+
+		sort.Strings(ids)
+		plan.Stacks[g.stackName(n)] = ids
+	}
+
+	sortStackNames(plan.Obsolete)
+
+	return plan
+}
+
+// existingStacks returns the logical IDs of the resources of all deployed stacks of the group.
+func (g *Group) existingStacks() (map[string][]string, error) {
+	existing := map[string][]string{}
+	input := &cloudformation.ListStacksInput{}
+
+	for _, status := range cloudformation.StackStatus_Values() {
+		if status != cloudformation.StackStatusDeleteComplete {
+			input.StackStatusFilter = append(input.StackStatusFilter, aws.String(status))
+		}
+	}
+
+	for {
+		out, err := g.CFClient.ListStacks(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing stacks: %w", err)
+		}
+
+		for _, summary := range out.StackSummaries {
+			if _, ok := g.stackNumber(aws.StringValue(summary.StackName)); ok {
+				existing[aws.StringValue(summary.StackName)] = nil
+			}
+		}
+
+		if out.NextToken == nil {
+			break
+		}
+
+		input.NextToken = out.NextToken
+	}
+
+	for name := range existing {
+		ids, err := g.stackResources(name)
+		if err != nil {
+			return nil, err
+		}
+
+		existing[name] = ids
+	}
+
+	return existing, nil
+}
+
+func (g *Group) stackResources(stackName string) ([]string, error) {
+	var ids []string
+
+	input := &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}
+
+	for {
+		out, err := g.CFClient.ListStackResources(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing resources of stack %s: %w", stackName, err)
+		}
+
+		for _, summary := range out.StackResourceSummaries {
+			ids = append(ids, aws.StringValue(summary.LogicalResourceId))
+		}
+
+		if out.NextToken == nil {
+			return ids, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
+
+// Plan returns how the resources of t are distributed to stacks, taking the deployed stacks into account.
+func (g *Group) Plan(t *template.Template) (*Plan, error) {
+	existing, err := g.existingStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Assign(existing, t.LogicalIDs()), nil
+}
+
+// Split returns the template of every stack of the plan. Parameters, mappings, conditions and metadata are
+// copied to every stack, outputs are not supported.
+func Split(t *template.Template, plan *Plan) (map[string]*template.Template, error) {
+	if len(t.Outputs) > 0 {
+		return nil, errors.New("templates deployed to a stack group can't have outputs")
+	}
+
+	templates := map[string]*template.Template{}
+
+	for name, ids := range plan.Stacks {
+		part := &template.Template{
+			AWSTemplateFormatVersion: t.AWSTemplateFormatVersion,
+			Description:              t.Description,
+			Metadata:                 t.Metadata,
+			Parameters:               t.Parameters,
+			Mappings:                 t.Mappings,
+			Conditions:               t.Conditions,
+			Resources:                map[string]*template.Resource{},
+		}
+
+		for _, id := range ids {
+			part.Resources[id] = t.Resources[id]
+		}
+
+		findings, err := part.Lint()
+		if err != nil {
+			return nil, fmt.Errorf("stack %s: %w", name, err)
+		}
+
+		for _, f := range findings {
+			if f.Severity == lint.SeverityError {
+				return nil, fmt.Errorf("stack %s: resources must not refer to each other: %s", name, f)
+			}
+		}
+
+		templates[name] = part
+	}
+
+	return templates, nil
+}
+
+// Deploy deploys the resources of t to the stacks of the group and deletes stacks which aren't needed anymore.
+func (g *Group) Deploy(t *template.Template, namedIAM bool) (*Plan, error) {
+	plan, err := g.Plan(t)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := Split(t, plan)
+	if err != nil {
+		return plan, err
+	}
+
+	for _, name := range plan.StackNames() {
+		g.logger().Infof("Deploying %d resources to stack %s.", len(plan.Stacks[name]), name)
+
+		if err = g.cloudformation(name).CloudFormationDeployTemplate(templates[name], namedIAM); err != nil {
+			return plan, fmt.Errorf("error deploying stack %s: %w", name, err)
+		}
+	}
+
+	for _, name := range plan.Obsolete {
+		g.logger().Infof("Deleting stack %s as it doesn't hold any resources anymore.", name)
+
+		if err = g.cloudformation(name).CloudFormationDelete(false); err != nil {
+			return plan, fmt.Errorf("error deleting stack %s: %w", name, err)
+		}
+	}
+
+	return plan, nil
+}
+
+func (g *Group) cloudformation(stackName string) *godeploycfn.Cloudformation {
+	c := &godeploycfn.Cloudformation{
+		CFClient:    g.CFClient,
+		StackName:   stackName,
+		LogrusEntry: g.logger(),
+	}
+
+	if g.Configure != nil {
+		g.Configure(c)
+	}
+
+	return c
+}
+
+// sortStackNames sorts <name>-<n> stack names by n.
+func sortStackNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+
+		return names[i] < names[j]
+	})
+}
@@ -0,0 +1,161 @@
+package stackgroup
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+
+	"github.com/moia-oss/go-deploy-cfn/template"
+)
+
+type mockStacksClient struct {
+	cloudformationiface.CloudFormationAPI
+	stacks map[string][]string
+}
+
+func (m mockStacksClient) ListStacks(*cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+	out := &cloudformation.ListStacksOutput{
+		StackSummaries: []*cloudformation.StackSummary{{StackName: aws.String("unrelated-stack")}},
+	}
+
+	for name := range m.stacks {
+		out.StackSummaries = append(out.StackSummaries, &cloudformation.StackSummary{StackName: aws.String(name)})
+	}
+
+	return out, nil
+}
+
+func (m mockStacksClient) ListStackResources(input *cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error) {
+	out := &cloudformation.ListStackResourcesOutput{}
+	for _, id := range m.stacks[*input.StackName] {
+		out.StackResourceSummaries = append(out.StackResourceSummaries, &cloudformation.StackResourceSummary{
+			LogicalResourceId: aws.String(id),
+		})
+	}
+
+	return out, nil
+}
+
+func ids(prefix string, n int) []string {
+	var out []string
+	for i := 0; i < n; i++ {
+		out = append(out, fmt.Sprintf("%s%02d", prefix, i))
+	}
+
+	return out
+}
+
+func TestGroup_Assign(t *testing.T) {
+	g := &Group{Name: "alarms", MaxResourcesPerStack: 3}
+
+	tests := []struct {
+		name         string
+		existing     map[string][]string
+		logicalIDs   []string
+		want         map[string][]string
+		wantObsolete []string
+	}{
+		{
+			name:       "Test initial assignment",
+			logicalIDs: ids("A", 7),
+			want: map[string][]string{
+				"alarms-1": {"A00", "A01", "A02"},
+				"alarms-2": {"A03", "A04", "A05"},
+				"alarms-3": {"A06"},
+			},
+		},
+		{
+			name: "Test resources stay in their stack and new ones fill gaps",
+			existing: map[string][]string{
+				"alarms-1": {"A00", "A01", "A02"},
+				"alarms-2": {"A03", "A04", "A05"},
+			},
+			logicalIDs: []string{"A00", "A02", "A03", "A04", "A05", "0New", "ZNew"},
+			want: map[string][]string{
+				"alarms-1": {"0New", "A00", "A02"},
+				"alarms-2": {"A03", "A04", "A05"},
+				"alarms-3": {"ZNew"},
+			},
+		},
+		{
+			name: "Test emptied stacks are obsolete",
+			existing: map[string][]string{
+				"alarms-1":  {"A00", "A01", "A02"},
+				"alarms-2":  {"A03", "A04", "A05"},
+				"alarms-10": {"A06"},
+			},
+			logicalIDs:   []string{"A04"},
+			want:         map[string][]string{"alarms-2": {"A04"}},
+			wantObsolete: []string{"alarms-1", "alarms-10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.Assign(tt.existing, tt.logicalIDs)
+
+			if !reflect.DeepEqual(got.Stacks, tt.want) {
+				t.Errorf("Assign() stacks = %v, want %v", got.Stacks, tt.want)
+			}
+
+			if !reflect.DeepEqual(got.Obsolete, tt.wantObsolete) {
+				t.Errorf("Assign() obsolete = %v, want %v", got.Obsolete, tt.wantObsolete)
+			}
+		})
+	}
+}
+
+func alarmTemplate(logicalIDs ...string) *template.Template {
+	t := template.New("alarms")
+	for _, id := range logicalIDs {
+		t.Resources[id] = &template.Resource{Type: "AWS::CloudWatch::Alarm", Properties: map[string]interface{}{"AlarmName": id}}
+	}
+
+	return t
+}
+
+func TestGroup_Plan(t *testing.T) {
+	g := &Group{
+		CFClient: mockStacksClient{stacks: map[string][]string{
+			"alarms-1": {"B", "C"},
+			"alarms-2": {"A"},
+		}},
+		Name:                 "alarms",
+		MaxResourcesPerStack: 2,
+	}
+
+	plan, err := g.Plan(alarmTemplate("A", "B", "D"))
+	if err != nil {
+		t.Fatalf("Plan() unexpected error = %v", err)
+	}
+
+	want := map[string][]string{"alarms-1": {"B", "D"}, "alarms-2": {"A"}}
+	if !reflect.DeepEqual(plan.Stacks, want) {
+		t.Errorf("Plan() = %v, want %v", plan.Stacks, want)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tmpl := alarmTemplate("A", "B")
+	plan := &Plan{Stacks: map[string][]string{"alarms-1": {"A"}, "alarms-2": {"B"}}}
+
+	templates, err := Split(tmpl, plan)
+	if err != nil {
+		t.Fatalf("Split() unexpected error = %v", err)
+	}
+
+	if len(templates["alarms-1"].Resources) != 1 || templates["alarms-2"].Resources["B"] == nil {
+		t.Errorf("Split() = %v", templates)
+	}
+
+	tmpl.Resources["B"].Properties["AlarmActions"] = []interface{}{template.Ref{Name: "A"}}
+
+	if _, err = Split(tmpl, plan); err == nil || !strings.Contains(err.Error(), "must not refer to each other") {
+		t.Errorf("Split() error = %v, want error for references across stacks", err)
+	}
+}
@@ -0,0 +1,171 @@
+package godeploycfn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// allowAllStackPolicy is what a stack without a stack policy effectively has. Once set, a stack policy
+// can't be removed again, so this is used to restore stacks which didn't have a policy before an override.
+const allowAllStackPolicy = `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`
+
+// ErrStackPolicyOverrideNotAllowed is returned if StackPolicyDuringUpdate is set without AllowStackPolicyOverride.
+var ErrStackPolicyOverrideNotAllowed = errors.New("StackPolicyDuringUpdate requires AllowStackPolicyOverride")
+
+func (c *Cloudformation) validateStackPolicyOptions() error {
+	if c.StackPolicyDuringUpdate != "" && !c.AllowStackPolicyOverride {
+		return ErrStackPolicyOverrideNotAllowed
+	}
+
+	return nil
+}
+
+func (c *Cloudformation) getStackPolicy() (string, error) {
+	//nolint
+	gspo, err := c.CFClient.GetStackPolicy(&cloudformation.GetStackPolicyInput{
+		StackName: aws.String(c.StackName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting stack policy: %w", err)
+	}
+
+	return aws.StringValue(gspo.StackPolicyBody), nil
+}
+
+func (c *Cloudformation) setStackPolicy(policy string) error {
+	_, err := c.CFClient.SetStackPolicy(&cloudformation.SetStackPolicyInput{
+		StackName:       aws.String(c.StackName),
+		StackPolicyBody: aws.String(policy),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting stack policy: %w", err)
+	}
+
+	return nil
+}
+
+// syncStackPolicy sets StackPolicy on the stack if it differs from the current one.
+func (c *Cloudformation) syncStackPolicy() error {
+	if c.StackPolicy == "" {
+		return nil
+	}
+
+	current, err := c.getStackPolicy()
+	if err != nil {
+		return err
+	}
+
+	equal, err := stackPoliciesEqual(current, c.StackPolicy)
+	if err != nil {
+		return err
+	}
+
+	if equal {
+		return nil
+	}
+
+	c.logger().Info("Stack policy differs from the deployed one. Updating it.")
+
+	return c.setStackPolicy(c.StackPolicy)
+}
+
+// executeWithStackPolicy executes the ChangeSet and takes care of the stack policy. New stacks get their policy
+// after creation. Existing stacks get their policy updated before the execution, and if an override is allowed,
+// the override is in place only while the ChangeSet is executed.
+func (c *Cloudformation) executeWithStackPolicy(changeSetName, changeSetType string) error {
+	if changeSetType == cloudformation.ChangeSetTypeCreate {
+		if err := c.executeChangeSet(changeSetName); err != nil {
+			return err
+		}
+
+		return c.syncStackPolicy()
+	}
+
+	if err := c.syncStackPolicy(); err != nil {
+		return err
+	}
+
+	if c.StackPolicyDuringUpdate == "" {
+		return c.executeChangeSet(changeSetName)
+	}
+
+	restorePolicy := c.StackPolicy
+	if restorePolicy == "" {
+		current, err := c.getStackPolicy()
+		if err != nil {
+			return err
+		}
+
+		restorePolicy = current
+	}
+
+	if restorePolicy == "" {
+		restorePolicy = allowAllStackPolicy
+	}
+
+	c.logger().Warn("Overriding the stack policy for this deployment.")
+
+	if err := c.setStackPolicy(c.StackPolicyDuringUpdate); err != nil {
+		return err
+	}
+
+	errExecute := c.executeChangeSet(changeSetName)
+
+	if err := c.setStackPolicy(restorePolicy); err != nil {
+		if errExecute != nil {
+			return fmt.Errorf("%w (restoring the stack policy failed as well: %v)", errExecute, err)
+		}
+
+		return fmt.Errorf("couldn't restore the stack policy after the override: %w", err)
+	}
+
+	return errExecute
+}
+
+// restoreStackPolicyAfterResume restores the stack policy after waiting for a ChangeSet whose execution was
+// started by an interrupted deployment, which may have left StackPolicyDuringUpdate in place. Without a
+// StackPolicy to sync, the policy before the override is unknown, so a remaining override is replaced with
+// allowAllStackPolicy.
+func (c *Cloudformation) restoreStackPolicyAfterResume() error {
+	if c.StackPolicy != "" || c.StackPolicyDuringUpdate == "" {
+		return c.syncStackPolicy()
+	}
+
+	current, err := c.getStackPolicy()
+	if err != nil {
+		return err
+	}
+
+	overridden, err := stackPoliciesEqual(current, c.StackPolicyDuringUpdate)
+	if err != nil || !overridden {
+		return err
+	}
+
+	c.logger().Warn("Removing the stack policy override left by the interrupted deployment.")
+
+	return c.setStackPolicy(allowAllStackPolicy)
+}
+
+// stackPoliciesEqual compares two JSON stack policies regardless of formatting and key order.
+func stackPoliciesEqual(a, b string) (bool, error) {
+	if a == "" || b == "" {
+		return a == b, nil
+	}
+
+	var va, vb interface{}
+
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		return false, fmt.Errorf("invalid stack policy: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		return false, fmt.Errorf("invalid stack policy: %w", err)
+	}
+
+	return reflect.DeepEqual(va, vb), nil
+}
@@ -0,0 +1,184 @@
+package godeploycfn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+type mockStackPolicyClient struct {
+	mockCFClient
+	policy  *string
+	setCall *[]string
+}
+
+func newMockStackPolicyClient(policy string) mockStackPolicyClient {
+	return mockStackPolicyClient{
+		mockCFClient: newMockCFClient(0, 0),
+		policy:       &policy,
+		setCall:      &[]string{},
+	}
+}
+
+func (m mockStackPolicyClient) GetStackPolicy(*cloudformation.GetStackPolicyInput) (*cloudformation.GetStackPolicyOutput, error) {
+	if *m.policy == "" {
+		return &cloudformation.GetStackPolicyOutput{}, nil
+	}
+
+	return &cloudformation.GetStackPolicyOutput{StackPolicyBody: aws.String(*m.policy)}, nil
+}
+
+func (m mockStackPolicyClient) SetStackPolicy(input *cloudformation.SetStackPolicyInput) (*cloudformation.SetStackPolicyOutput, error) {
+	*m.policy = *input.StackPolicyBody
+	*m.setCall = append(*m.setCall, *input.StackPolicyBody)
+
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
+
+const (
+	denyReplacePolicy = `{"Statement":[{"Effect":"Deny","Action":"Update:Replace","Principal":"*","Resource":"LogicalResourceId/Database"}]}`
+	overridePolicy    = `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"LogicalResourceId/Database"}]}`
+)
+
+func TestCloudformation_executeWithStackPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		changeSetType string
+		deployed      string
+		policy        string
+		override      string
+		wantSetCalls  []string
+	}{
+		{
+			name:          "Test policy is set on create",
+			changeSetType: cloudformation.ChangeSetTypeCreate,
+			policy:        denyReplacePolicy,
+			wantSetCalls:  []string{denyReplacePolicy},
+		},
+		{
+			name:          "Test equal policy is not updated",
+			changeSetType: cloudformation.ChangeSetTypeUpdate,
+			deployed: `{
+				"Statement": [{"Resource": "LogicalResourceId/Database", "Principal": "*", "Action": "Update:Replace", "Effect": "Deny"}]
+			}`,
+			policy: denyReplacePolicy,
+		},
+		{
+			name:          "Test differing policy is updated",
+			changeSetType: cloudformation.ChangeSetTypeUpdate,
+			deployed:      allowAllStackPolicy,
+			policy:        denyReplacePolicy,
+			wantSetCalls:  []string{denyReplacePolicy},
+		},
+		{
+			name:          "Test override is restored",
+			changeSetType: cloudformation.ChangeSetTypeUpdate,
+			deployed:      denyReplacePolicy,
+			override:      overridePolicy,
+			wantSetCalls:  []string{overridePolicy, denyReplacePolicy},
+		},
+		{
+			name:          "Test override restores allow all on stacks without policy",
+			changeSetType: cloudformation.ChangeSetTypeUpdate,
+			override:      overridePolicy,
+			wantSetCalls:  []string{overridePolicy, allowAllStackPolicy},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockStackPolicyClient(tt.deployed)
+			c := &Cloudformation{
+				CFClient:                 client,
+				StackName:                "test update stack",
+				StackPolicy:              tt.policy,
+				StackPolicyDuringUpdate:  tt.override,
+				AllowStackPolicyOverride: tt.override != "",
+			}
+
+			if err := c.executeWithStackPolicy("cs", tt.changeSetType); err != nil {
+				t.Fatalf("executeWithStackPolicy() unexpected error = %v", err)
+			}
+
+			if len(*client.setCall) != len(tt.wantSetCalls) {
+				t.Fatalf("SetStackPolicy calls = %v, want %v", *client.setCall, tt.wantSetCalls)
+			}
+
+			for i, want := range tt.wantSetCalls {
+				if (*client.setCall)[i] != want {
+					t.Errorf("SetStackPolicy call %d = %s, want %s", i, (*client.setCall)[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudformation_validateStackPolicyOptions(t *testing.T) {
+	c := &Cloudformation{StackPolicyDuringUpdate: overridePolicy}
+	if err := c.validateStackPolicyOptions(); !errors.Is(err, ErrStackPolicyOverrideNotAllowed) {
+		t.Errorf("validateStackPolicyOptions() error = %v, want %v", err, ErrStackPolicyOverrideNotAllowed)
+	}
+
+	c.AllowStackPolicyOverride = true
+	if err := c.validateStackPolicyOptions(); err != nil {
+		t.Errorf("validateStackPolicyOptions() unexpected error = %v", err)
+	}
+}
+
+type mockUnchangedStackPolicyClient struct {
+	mockDeploymentHashClient
+	policy  *string
+	setCall *[]string
+}
+
+func (m mockUnchangedStackPolicyClient) GetStackPolicy(*cloudformation.GetStackPolicyInput) (*cloudformation.GetStackPolicyOutput, error) {
+	return &cloudformation.GetStackPolicyOutput{StackPolicyBody: aws.String(*m.policy)}, nil
+}
+
+func (m mockUnchangedStackPolicyClient) SetStackPolicy(input *cloudformation.SetStackPolicyInput) (*cloudformation.SetStackPolicyOutput, error) {
+	*m.policy = *input.StackPolicyBody
+	*m.setCall = append(*m.setCall, *input.StackPolicyBody)
+
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
+
+func TestCloudformation_CloudFormationDeploy_unchangedStackPolicy(t *testing.T) {
+	hash, err := (&Cloudformation{}).DeploymentHash(hashTemplate, false)
+	if err != nil {
+		t.Fatalf("DeploymentHash() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		deployed     string
+		wantSetCalls int
+	}{
+		{name: "Test differing policy is updated", deployed: allowAllStackPolicy, wantSetCalls: 1},
+		{name: "Test equal policy is kept", deployed: denyReplacePolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockUnchangedStackPolicyClient{
+				mockDeploymentHashClient: mockDeploymentHashClient{
+					status: cloudformation.StackStatusUpdateComplete,
+					hash:   hash,
+					tags:   new([]*cloudformation.Tag),
+				},
+				policy:  aws.String(tt.deployed),
+				setCall: &[]string{},
+			}
+			c := &Cloudformation{CFClient: client, StackName: "test", SkipUnchanged: true, StackPolicy: denyReplacePolicy}
+
+			if err := c.CloudFormationDeploy(hashTemplate, false); err != nil {
+				t.Fatalf("CloudFormationDeploy() unexpected error = %v", err)
+			}
+
+			if len(*client.setCall) != tt.wantSetCalls || *client.policy != denyReplacePolicy {
+				t.Errorf("SetStackPolicy calls = %v, want %d", *client.setCall, tt.wantSetCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package godeploycfn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const maxRetryTimeForStackSetOperation = time.Minute * 30
+
+// StackSet is the counterpart of Cloudformation for StackSets, which deploy a template to several
+// accounts or organizational units and regions.
+type StackSet struct {
+	CFClient     cloudformationiface.CloudFormationAPI
+	StackSetName string
+	LogrusEntry  *logrus.Entry
+	Parameters   []*cloudformation.Parameter
+	Tags         []*cloudformation.Tag
+	// PermissionModel is either SELF_MANAGED (default) or SERVICE_MANAGED.
+	PermissionModel string
+	// AdministrationRoleARN and ExecutionRoleName are only used by SELF_MANAGED stack sets.
+	AdministrationRoleARN string
+	ExecutionRoleName     string
+	// AutoDeployment is only used by SERVICE_MANAGED stack sets.
+	AutoDeployment *cloudformation.AutoDeployment
+	// CallAs is SELF (default) or DELEGATED_ADMIN.
+	CallAs string
+	// OperationPreferences control failure tolerance, concurrency and region order of all operations.
+	OperationPreferences *cloudformation.StackSetOperationPreferences
+	// PruneInstances deletes stack instances which are not targeted anymore.
+	PruneInstances bool
+	// RetainStacks keeps the stacks of pruned stack instances.
+	RetainStacks bool
+}
+
+// StackSetTargets describe where stack instances are deployed to. Accounts are used by SELF_MANAGED,
+// OrganizationalUnitIDs by SERVICE_MANAGED stack sets.
+type StackSetTargets struct {
+	Accounts              []string
+	OrganizationalUnitIDs []string
+	Regions               []string
+}
+
+// StackSetOperationResult is the outcome of a single stack set operation.
+type StackSetOperationResult struct {
+	OperationID  string
+	Action       string
+	Status       string
+	StatusReason string
+	Instances    []StackInstanceResult
+}
+
+// StackInstanceResult is the outcome of a stack set operation for a single account or OU and region.
+type StackInstanceResult struct {
+	Account              string
+	OrganizationalUnitID string
+	Region               string
+	Status               string
+	StatusReason         string
+}
+
+// StackSetOperationError is returned if a stack set operation did not succeed.
+type StackSetOperationError struct {
+	Result *StackSetOperationResult
+}
+
+func (e *StackSetOperationError) Error() string {
+	var failed []string
+
+	for _, i := range e.Result.Instances {
+		if i.Status == cloudformation.StackSetOperationResultStatusFailed {
+			target := i.Account
+			if target == "" {
+				target = i.OrganizationalUnitID
+			}
+
+			failed = append(failed, fmt.Sprintf("%s/%s: %s", target, i.Region, i.StatusReason))
+		}
+	}
+
+	msg := fmt.Sprintf("stack set operation %s (%s) finished with status %s", e.Result.OperationID, e.Result.Action, e.Result.Status)
+	if e.Result.StatusReason != "" {
+		msg += ": " + e.Result.StatusReason
+	}
+
+	if len(failed) > 0 {
+		msg += "; failed instances: " + strings.Join(failed, ", ")
+	}
+
+	return msg
+}
+
+func (s *StackSet) logger() *logrus.Entry {
+	fields := logrus.Fields{
+		"stack_set_name": s.StackSetName,
+	}
+
+	if s.LogrusEntry == nil {
+		return logrus.WithFields(fields)
+	}
+
+	return s.LogrusEntry.WithFields(fields)
+}
+
+func (s *StackSet) serviceManaged() bool {
+	return s.PermissionModel == cloudformation.PermissionModelsServiceManaged
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
+
+func capabilities(namedIAM bool) []*string {
+	if !namedIAM {
+		return nil
+	}
+
+	return []*string{aws.String(cloudformation.CapabilityCapabilityNamedIam)}
+}
+
+func (s *StackSet) exists() (bool, error) {
+	_, err := s.CFClient.DescribeStackSet(&cloudformation.DescribeStackSetInput{
+		CallAs:       optionalString(s.CallAs),
+		StackSetName: aws.String(s.StackSetName),
+	})
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == cloudformation.ErrCodeStackSetNotFoundException {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("error describing stack set: %w", err)
+	}
+
+	return true, nil
+}
+
+// StackSetDeploy creates or updates the stack set with the given template, waits for existing stack instances
+// to be updated and creates stack instances for all targets which don't have one yet. The results of all
+// operations are returned, also if one of them failed.
+func (s *StackSet) StackSetDeploy(templateBody string, namedIAM bool, targets StackSetTargets) ([]*StackSetOperationResult, error) {
+	exists, err := s.exists()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*StackSetOperationResult
+
+	if exists {
+		var operationID string
+
+		operationID, err = s.update(templateBody, namedIAM)
+		if err != nil {
+			return nil, err
+		}
+
+		var result *StackSetOperationResult
+
+		result, err = s.waitForOperation(operationID)
+		if result != nil {
+			results = append(results, result)
+		}
+
+		if err != nil {
+			return results, err
+		}
+	} else if err = s.create(templateBody, namedIAM); err != nil {
+		return nil, err
+	}
+
+	instanceResults, err := s.SyncStackInstances(targets)
+
+	return append(results, instanceResults...), err
+}
+
+func (s *StackSet) create(templateBody string, namedIAM bool) error {
+	s.logger().Info("Creating stack set.")
+
+	_, err := s.CFClient.CreateStackSet(&cloudformation.CreateStackSetInput{
+		AdministrationRoleARN: optionalString(s.AdministrationRoleARN),
+		AutoDeployment:        s.AutoDeployment,
+		CallAs:                optionalString(s.CallAs),
+		Capabilities:          capabilities(namedIAM),
+		ClientRequestToken:    aws.String(uuid.NewString()),
+		ExecutionRoleName:     optionalString(s.ExecutionRoleName),
+		Parameters:            s.Parameters,
+		PermissionModel:       optionalString(s.PermissionModel),
+		StackSetName:          aws.String(s.StackSetName),
+		Tags:                  s.Tags,
+		TemplateBody:          aws.String(templateBody),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating stack set: %w", err)
+	}
+
+	return nil
+}
+
+func (s *StackSet) update(templateBody string, namedIAM bool) (string, error) {
+	s.logger().Info("Updating stack set.")
+
+	operationID := uuid.NewString()
+
+	_, err := s.CFClient.UpdateStackSet(&cloudformation.UpdateStackSetInput{
+		AdministrationRoleARN: optionalString(s.AdministrationRoleARN),
+		AutoDeployment:        s.AutoDeployment,
+		CallAs:                optionalString(s.CallAs),
+		Capabilities:          capabilities(namedIAM),
+		ExecutionRoleName:     optionalString(s.ExecutionRoleName),
+		OperationId:           aws.String(operationID),
+		OperationPreferences:  s.OperationPreferences,
+		Parameters:            s.Parameters,
+		PermissionModel:       optionalString(s.PermissionModel),
+		StackSetName:          aws.String(s.StackSetName),
+		Tags:                  s.Tags,
+		TemplateBody:          aws.String(templateBody),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating stack set: %w", err)
+	}
+
+	return operationID, nil
+}
+
+// instanceKey identifies a stack instance by account (or OU) and region.
+type instanceKey struct {
+	target string
+	region string
+}
+
+func (s *StackSet) listStackInstances() (map[instanceKey]bool, error) {
+	instances := map[instanceKey]bool{}
+	input := &cloudformation.ListStackInstancesInput{
+		CallAs:       optionalString(s.CallAs),
+		StackSetName: aws.String(s.StackSetName),
+	}
+
+	for {
+		out, err := s.CFClient.ListStackInstances(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing stack instances: %w", err)
+		}
+
+		for _, summary := range out.Summaries {
+			target := aws.StringValue(summary.Account)
+			if s.serviceManaged() {
+				target = aws.StringValue(summary.OrganizationalUnitId)
+			}
+
+			instances[instanceKey{target: target, region: aws.StringValue(summary.Region)}] = true
+		}
+
+		if out.NextToken == nil {
+			return instances, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
+
+// instanceGroup is a batch of stack instances which can be handled by a single operation.
+type instanceGroup struct {
+	targets []string
+	regions []string
+}
+
+// groupInstances groups stack instances into as few (targets x regions) batches as possible.
+func groupInstances(keys []instanceKey) []instanceGroup {
+	targetsByRegion := map[string][]string{}
+	for _, k := range keys {
+		targetsByRegion[k.region] = append(targetsByRegion[k.region], k.target)
+	}
+
+	regionsByTargets := map[string][]string{}
+
+	for region, targets := range targetsByRegion {
+		sort.Strings(targets)
+		joined := strings.Join(targets, ",")
+		regionsByTargets[joined] = append(regionsByTargets[joined], region)
+	}
+
+	groups := make([]instanceGroup, 0, len(regionsByTargets))
+	for joined, regions := range regionsByTargets {
+		groups = append(groups, instanceGroup{targets: strings.Split(joined, ","), regions: regions})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.Join(groups[i].targets, ",") < strings.Join(groups[j].targets, ",")
+	})
+
+	return groups
+}
+
+// orderRegions sorts regions according to the RegionOrder of the operation preferences.
+func (s *StackSet) orderRegions(regions []string) []string {
+	position := map[string]int{}
+
+	if s.OperationPreferences != nil {
+		for i, r := range s.OperationPreferences.RegionOrder {
+			position[aws.StringValue(r)] = i - len(s.OperationPreferences.RegionOrder)
+		}
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		if position[regions[i]] != position[regions[j]] {
+			return position[regions[i]] < position[regions[j]]
+		}
+
+		return regions[i] < regions[j]
+	})
+
+	return regions
+}
+
+func (s *StackSet) deploymentTargets(targets []string) ([]*string, *cloudformation.DeploymentTargets) {
+	if s.serviceManaged() {
+		return nil, &cloudformation.DeploymentTargets{OrganizationalUnitIds: aws.StringSlice(targets)}
+	}
+
+	return aws.StringSlice(targets), nil
+}
+
+// SyncStackInstances creates stack instances for all targets which don't have one yet and, if PruneInstances is
+// set, deletes the stack instances which aren't targeted anymore.
+func (s *StackSet) SyncStackInstances(targets StackSetTargets) ([]*StackSetOperationResult, error) {
+	existing, err := s.listStackInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := targets.Accounts
+	if s.serviceManaged() {
+		wanted = targets.OrganizationalUnitIDs
+	}
+
+	var missing, obsolete []instanceKey
+
+	targeted := map[instanceKey]bool{}
+
+	for _, target := range wanted {
+		for _, region := range targets.Regions {
+			k := instanceKey{target: target, region: region}
+			targeted[k] = true
+
+			if !existing[k] {
+				missing = append(missing, k)
+			}
+		}
+	}
+
+	for k := range existing {
+		if !targeted[k] {
+			obsolete = append(obsolete, k)
+		}
+	}
+
+	var results []*StackSetOperationResult
+
+	for _, group := range groupInstances(missing) {
+		result, err := s.createStackInstances(group.targets, s.orderRegions(group.regions))
+		if result != nil {
+			results = append(results, result)
+		}
+
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if !s.PruneInstances {
+		return results, nil
+	}
+
+	for _, group := range groupInstances(obsolete) {
+		result, err := s.deleteStackInstances(group.targets, s.orderRegions(group.regions))
+		if result != nil {
+			results = append(results, result)
+		}
+
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func (s *StackSet) createStackInstances(targets, regions []string) (*StackSetOperationResult, error) {
+	s.logger().Infof("Creating stack instances for %v in %v.", targets, regions)
+
+	operationID := uuid.NewString()
+	accounts, deploymentTargets := s.deploymentTargets(targets)
+
+	_, err := s.CFClient.CreateStackInstances(&cloudformation.CreateStackInstancesInput{
+		Accounts:             accounts,
+		CallAs:               optionalString(s.CallAs),
+		DeploymentTargets:    deploymentTargets,
+		OperationId:          aws.String(operationID),
+		OperationPreferences: s.OperationPreferences,
+		Regions:              aws.StringSlice(regions),
+		StackSetName:         aws.String(s.StackSetName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating stack instances: %w", err)
+	}
+
+	return s.waitForOperation(operationID)
+}
+
+func (s *StackSet) deleteStackInstances(targets, regions []string) (*StackSetOperationResult, error) {
+	s.logger().Infof("Deleting stack instances for %v in %v.", targets, regions)
+
+	operationID := uuid.NewString()
+	accounts, deploymentTargets := s.deploymentTargets(targets)
+
+	_, err := s.CFClient.DeleteStackInstances(&cloudformation.DeleteStackInstancesInput{
+		Accounts:             accounts,
+		CallAs:               optionalString(s.CallAs),
+		DeploymentTargets:    deploymentTargets,
+		OperationId:          aws.String(operationID),
+		OperationPreferences: s.OperationPreferences,
+		Regions:              aws.StringSlice(regions),
+		RetainStacks:         aws.Bool(s.RetainStacks),
+		StackSetName:         aws.String(s.StackSetName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deleting stack instances: %w", err)
+	}
+
+	return s.waitForOperation(operationID)
+}
+
+// waitForOperation waits until the operation finished and collects the results of all stack instances.
+// A *StackSetOperationError is returned if the operation did not succeed.
+func (s *StackSet) waitForOperation(operationID string) (*StackSetOperationResult, error) {
+	back := &backoff.ExponentialBackOff{
+		InitialInterval:     initialRetryPeriod,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         maxRetryInterval,
+		MaxElapsedTime:      maxRetryTimeForStackSetOperation,
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}
+
+	var operation *cloudformation.StackSetOperation
+
+	err := backoff.Retry(func() error {
+		dsso, err := s.CFClient.DescribeStackSetOperation(&cloudformation.DescribeStackSetOperationInput{
+			CallAs:       optionalString(s.CallAs),
+			OperationId:  aws.String(operationID),
+			StackSetName: aws.String(s.StackSetName),
+		})
+		if err != nil {
+			return fmt.Errorf("encountered an error when describing the stack set operation: %w", err)
+		}
+
+		operation = dsso.StackSetOperation
+
+		switch status := aws.StringValue(operation.Status); status {
+		case cloudformation.StackSetOperationStatusRunning, cloudformation.StackSetOperationStatusQueued,
+			cloudformation.StackSetOperationStatusStopping:
+			s.logger().Infof("Stack set operation %s is %s. Will check again.", operationID, status)
+
+			return fmt.Errorf("stack set operation not complete yet, status: %s", status)
+		}
+
+		return nil
+	}, back)
+	if err != nil {
+		return nil, fmt.Errorf("maximum wait time of %s for stack set operation %s has passed: %w",
+			maxRetryTimeForStackSetOperation, operationID, err)
+	}
+
+	result := &StackSetOperationResult{
+		OperationID:  operationID,
+		Action:       aws.StringValue(operation.Action),
+		Status:       aws.StringValue(operation.Status),
+		StatusReason: aws.StringValue(operation.StatusReason),
+	}
+
+	if result.Instances, err = s.listOperationResults(operationID); err != nil {
+		return result, err
+	}
+
+	if result.Status != cloudformation.StackSetOperationStatusSucceeded {
+		return result, &StackSetOperationError{Result: result}
+	}
+
+	s.logger().Infof("Stack set operation %s has succeeded.", operationID)
+
+	return result, nil
+}
+
+func (s *StackSet) listOperationResults(operationID string) ([]StackInstanceResult, error) {
+	var results []StackInstanceResult
+
+	input := &cloudformation.ListStackSetOperationResultsInput{
+		CallAs:       optionalString(s.CallAs),
+		OperationId:  aws.String(operationID),
+		StackSetName: aws.String(s.StackSetName),
+	}
+
+	for {
+		out, err := s.CFClient.ListStackSetOperationResults(input)
+		if err != nil {
+			return nil, fmt.Errorf("error listing stack set operation results: %w", err)
+		}
+
+		for _, summary := range out.Summaries {
+			results = append(results, StackInstanceResult{
+				Account:              aws.StringValue(summary.Account),
+				OrganizationalUnitID: aws.StringValue(summary.OrganizationalUnitId),
+				Region:               aws.StringValue(summary.Region),
+				Status:               aws.StringValue(summary.Status),
+				StatusReason:         aws.StringValue(summary.StatusReason),
+			})
+		}
+
+		if out.NextToken == nil {
+			return results, nil
+		}
+
+		input.NextToken = out.NextToken
+	}
+}
@@ -0,0 +1,213 @@
+package godeploycfn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockStackSetClient struct {
+	cloudformationiface.CloudFormationAPI
+	exists    bool
+	instances []*cloudformation.StackInstanceSummary
+	status    string
+	calls     *[]string
+}
+
+func (m mockStackSetClient) DescribeStackSet(*cloudformation.DescribeStackSetInput) (*cloudformation.DescribeStackSetOutput, error) {
+	if !m.exists {
+		return nil, awserr.New(cloudformation.ErrCodeStackSetNotFoundException, "not found", nil)
+	}
+
+	return &cloudformation.DescribeStackSetOutput{}, nil
+}
+
+func (m mockStackSetClient) CreateStackSet(*cloudformation.CreateStackSetInput) (*cloudformation.CreateStackSetOutput, error) {
+	*m.calls = append(*m.calls, "CreateStackSet")
+
+	return &cloudformation.CreateStackSetOutput{}, nil
+}
+
+func (m mockStackSetClient) UpdateStackSet(*cloudformation.UpdateStackSetInput) (*cloudformation.UpdateStackSetOutput, error) {
+	*m.calls = append(*m.calls, "UpdateStackSet")
+
+	return &cloudformation.UpdateStackSetOutput{}, nil
+}
+
+func (m mockStackSetClient) ListStackInstances(*cloudformation.ListStackInstancesInput) (*cloudformation.ListStackInstancesOutput, error) {
+	return &cloudformation.ListStackInstancesOutput{Summaries: m.instances}, nil
+}
+
+func (m mockStackSetClient) CreateStackInstances(input *cloudformation.CreateStackInstancesInput) (*cloudformation.CreateStackInstancesOutput, error) {
+	call := "CreateStackInstances"
+	for _, a := range input.Accounts {
+		call += " " + *a
+	}
+
+	for _, r := range input.Regions {
+		call += " " + *r
+	}
+
+	*m.calls = append(*m.calls, call)
+
+	return &cloudformation.CreateStackInstancesOutput{}, nil
+}
+
+func (m mockStackSetClient) DeleteStackInstances(input *cloudformation.DeleteStackInstancesInput) (*cloudformation.DeleteStackInstancesOutput, error) {
+	call := "DeleteStackInstances"
+	for _, a := range input.Accounts {
+		call += " " + *a
+	}
+
+	for _, r := range input.Regions {
+		call += " " + *r
+	}
+
+	*m.calls = append(*m.calls, call)
+
+	return &cloudformation.DeleteStackInstancesOutput{}, nil
+}
+
+func (m mockStackSetClient) DescribeStackSetOperation(
+	input *cloudformation.DescribeStackSetOperationInput,
+) (*cloudformation.DescribeStackSetOperationOutput, error) {
+	return &cloudformation.DescribeStackSetOperationOutput{
+		StackSetOperation: &cloudformation.StackSetOperation{
+			OperationId: input.OperationId,
+			Status:      aws.String(m.status),
+		},
+	}, nil
+}
+
+func (m mockStackSetClient) ListStackSetOperationResults(
+	*cloudformation.ListStackSetOperationResultsInput,
+) (*cloudformation.ListStackSetOperationResultsOutput, error) {
+	status := cloudformation.StackSetOperationResultStatusSucceeded
+	if m.status != cloudformation.StackSetOperationStatusSucceeded {
+		status = cloudformation.StackSetOperationResultStatusFailed
+	}
+
+	return &cloudformation.ListStackSetOperationResultsOutput{
+		Summaries: []*cloudformation.StackSetOperationResultSummary{
+			{
+				Account:      aws.String("111111111111"),
+				Region:       aws.String("eu-central-1"),
+				Status:       aws.String(status),
+				StatusReason: aws.String("reason"),
+			},
+		},
+	}, nil
+}
+
+func instance(account, region string) *cloudformation.StackInstanceSummary {
+	return &cloudformation.StackInstanceSummary{Account: aws.String(account), Region: aws.String(region)}
+}
+
+func TestStackSet_StackSetDeploy(t *testing.T) {
+	targets := StackSetTargets{
+		Accounts: []string{"111111111111", "222222222222"},
+		Regions:  []string{"eu-west-1", "eu-central-1"},
+	}
+
+	tests := []struct {
+		name      string
+		exists    bool
+		instances []*cloudformation.StackInstanceSummary
+		prune     bool
+		status    string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:   "Test new stack set",
+			status: cloudformation.StackSetOperationStatusSucceeded,
+			wantCalls: []string{
+				"CreateStackSet",
+				"CreateStackInstances 111111111111 222222222222 eu-central-1 eu-west-1",
+			},
+		},
+		{
+			name:   "Test existing stack set with a missing instance",
+			exists: true,
+			instances: []*cloudformation.StackInstanceSummary{
+				instance("111111111111", "eu-west-1"),
+				instance("111111111111", "eu-central-1"),
+				instance("222222222222", "eu-central-1"),
+				instance("333333333333", "eu-central-1"),
+			},
+			status: cloudformation.StackSetOperationStatusSucceeded,
+			wantCalls: []string{
+				"UpdateStackSet",
+				"CreateStackInstances 222222222222 eu-west-1",
+			},
+		},
+		{
+			name:   "Test pruning untargeted instances",
+			exists: true,
+			instances: []*cloudformation.StackInstanceSummary{
+				instance("111111111111", "eu-west-1"),
+				instance("111111111111", "eu-central-1"),
+				instance("222222222222", "eu-west-1"),
+				instance("222222222222", "eu-central-1"),
+				instance("333333333333", "eu-central-1"),
+			},
+			prune:  true,
+			status: cloudformation.StackSetOperationStatusSucceeded,
+			wantCalls: []string{
+				"UpdateStackSet",
+				"DeleteStackInstances 333333333333 eu-central-1",
+			},
+		},
+		{
+			name:      "Test failed update",
+			exists:    true,
+			status:    cloudformation.StackSetOperationStatusFailed,
+			wantCalls: []string{"UpdateStackSet"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+
+			s := &StackSet{
+				CFClient: mockStackSetClient{
+					exists:    tt.exists,
+					instances: tt.instances,
+					status:    tt.status,
+					calls:     &calls,
+				},
+				StackSetName:   "guardrails",
+				PruneInstances: tt.prune,
+				OperationPreferences: &cloudformation.StackSetOperationPreferences{
+					RegionOrder: aws.StringSlice([]string{"eu-central-1"}),
+				},
+			}
+
+			results, err := s.StackSetDeploy("template", false, targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StackSetDeploy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var opErr *StackSetOperationError
+			if tt.wantErr && (!errors.As(err, &opErr) || len(results) != 1) {
+				t.Errorf("expected a StackSetOperationError and the failed result, got %v and %v", err, results)
+			}
+
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			for i := range calls {
+				if calls[i] != tt.wantCalls[i] {
+					t.Errorf("call %d = %s, want %s", i, calls[i], tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
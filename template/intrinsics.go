@@ -0,0 +1,271 @@
+package template
+
+import (
+	"encoding/json"
+)
+
+// Pseudo parameters, to be used with Ref.
+var (
+	AccountID = Ref{Name: "AWS::AccountId"}
+	NoValue   = Ref{Name: "AWS::NoValue"}
+	Partition = Ref{Name: "AWS::Partition"}
+	Region    = Ref{Name: "AWS::Region"}
+	StackID   = Ref{Name: "AWS::StackId"}
+	StackName = Ref{Name: "AWS::StackName"}
+	URLSuffix = Ref{Name: "AWS::URLSuffix"}
+)
+
+// function is the long form of an intrinsic function, e.g. {"Fn::GetAtt": ["Resource", "Arn"]}.
+type function map[string]interface{}
+
+// Ref references a parameter, resource or pseudo parameter.
+type Ref struct {
+	Name string
+}
+
+func (r Ref) function() function { return function{"Ref": r.Name} }
+
+// MarshalJSON implements json.Marshaler.
+func (r Ref) MarshalJSON() ([]byte, error) { return json.Marshal(r.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (r Ref) MarshalYAML() (interface{}, error) { return r.function(), nil }
+
+// GetAtt returns the attribute of a resource.
+type GetAtt struct {
+	Resource  string
+	Attribute string
+}
+
+func (g GetAtt) function() function {
+	return function{"Fn::GetAtt": []string{g.Resource, g.Attribute}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g GetAtt) MarshalJSON() ([]byte, error) { return json.Marshal(g.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (g GetAtt) MarshalYAML() (interface{}, error) { return g.function(), nil }
+
+// Sub substitutes ${Name} variables in String, using Variables before parameters, resources and attributes.
+type Sub struct {
+	String    string
+	Variables map[string]interface{}
+}
+
+func (s Sub) function() function {
+	if len(s.Variables) == 0 {
+		return function{"Fn::Sub": s.String}
+	}
+
+	return function{"Fn::Sub": []interface{}{s.String, s.Variables}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Sub) MarshalJSON() ([]byte, error) { return json.Marshal(s.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Sub) MarshalYAML() (interface{}, error) { return s.function(), nil }
+
+// Join joins Values with Delimiter.
+type Join struct {
+	Delimiter string
+	Values    []interface{}
+}
+
+func (j Join) function() function {
+	return function{"Fn::Join": []interface{}{j.Delimiter, nonNil(j.Values)}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j Join) MarshalJSON() ([]byte, error) { return json.Marshal(j.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (j Join) MarshalYAML() (interface{}, error) { return j.function(), nil }
+
+// Select returns the element at Index of List.
+type Select struct {
+	Index int
+	List  interface{}
+}
+
+func (s Select) function() function {
+	return function{"Fn::Select": []interface{}{s.Index, s.List}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Select) MarshalJSON() ([]byte, error) { return json.Marshal(s.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Select) MarshalYAML() (interface{}, error) { return s.function(), nil }
+
+// Split splits Source at Delimiter.
+type Split struct {
+	Delimiter string
+	Source    interface{}
+}
+
+func (s Split) function() function {
+	return function{"Fn::Split": []interface{}{s.Delimiter, s.Source}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Split) MarshalJSON() ([]byte, error) { return json.Marshal(s.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Split) MarshalYAML() (interface{}, error) { return s.function(), nil }
+
+// FindInMap returns a value of the Mappings section.
+type FindInMap struct {
+	MapName   string
+	TopLevel  interface{}
+	SecondKey interface{}
+}
+
+func (f FindInMap) function() function {
+	return function{"Fn::FindInMap": []interface{}{f.MapName, f.TopLevel, f.SecondKey}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f FindInMap) MarshalJSON() ([]byte, error) { return json.Marshal(f.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (f FindInMap) MarshalYAML() (interface{}, error) { return f.function(), nil }
+
+// ImportValue imports the output exported by another stack.
+type ImportValue struct {
+	Name interface{}
+}
+
+func (i ImportValue) function() function { return function{"Fn::ImportValue": i.Name} }
+
+// MarshalJSON implements json.Marshaler.
+func (i ImportValue) MarshalJSON() ([]byte, error) { return json.Marshal(i.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (i ImportValue) MarshalYAML() (interface{}, error) { return i.function(), nil }
+
+// Base64 encodes Value.
+type Base64 struct {
+	Value interface{}
+}
+
+func (b Base64) function() function { return function{"Fn::Base64": b.Value} }
+
+// MarshalJSON implements json.Marshaler.
+func (b Base64) MarshalJSON() ([]byte, error) { return json.Marshal(b.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (b Base64) MarshalYAML() (interface{}, error) { return b.function(), nil }
+
+// GetAZs returns the availability zones of Region, or of the stack's region if empty.
+type GetAZs struct {
+	Region interface{}
+}
+
+func (g GetAZs) function() function {
+	if g.Region == nil {
+		return function{"Fn::GetAZs": ""}
+	}
+
+	return function{"Fn::GetAZs": g.Region}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g GetAZs) MarshalJSON() ([]byte, error) { return json.Marshal(g.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (g GetAZs) MarshalYAML() (interface{}, error) { return g.function(), nil }
+
+// If returns True if Condition holds, False otherwise.
+type If struct {
+	Condition string
+	True      interface{}
+	False     interface{}
+}
+
+func (i If) function() function {
+	return function{"Fn::If": []interface{}{i.Condition, i.True, i.False}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i If) MarshalJSON() ([]byte, error) { return json.Marshal(i.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (i If) MarshalYAML() (interface{}, error) { return i.function(), nil }
+
+// Equals is a condition function comparing two values.
+type Equals struct {
+	A interface{}
+	B interface{}
+}
+
+func (e Equals) function() function {
+	return function{"Fn::Equals": []interface{}{e.A, e.B}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e Equals) MarshalJSON() ([]byte, error) { return json.Marshal(e.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (e Equals) MarshalYAML() (interface{}, error) { return e.function(), nil }
+
+// Not negates a condition function.
+type Not struct {
+	Condition interface{}
+}
+
+func (n Not) function() function { return function{"Fn::Not": []interface{}{n.Condition}} }
+
+// MarshalJSON implements json.Marshaler.
+func (n Not) MarshalJSON() ([]byte, error) { return json.Marshal(n.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (n Not) MarshalYAML() (interface{}, error) { return n.function(), nil }
+
+// And holds if all of the conditions hold.
+type And struct {
+	Conditions []interface{}
+}
+
+func (a And) function() function { return function{"Fn::And": nonNil(a.Conditions)} }
+
+// MarshalJSON implements json.Marshaler.
+func (a And) MarshalJSON() ([]byte, error) { return json.Marshal(a.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (a And) MarshalYAML() (interface{}, error) { return a.function(), nil }
+
+// Or holds if any of the conditions holds.
+type Or struct {
+	Conditions []interface{}
+}
+
+func (o Or) function() function { return function{"Fn::Or": nonNil(o.Conditions)} }
+
+// MarshalJSON implements json.Marshaler.
+func (o Or) MarshalJSON() ([]byte, error) { return json.Marshal(o.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (o Or) MarshalYAML() (interface{}, error) { return o.function(), nil }
+
+// Condition references a named condition inside condition functions.
+type Condition struct {
+	Name string
+}
+
+func (c Condition) function() function { return function{"Condition": c.Name} }
+
+// MarshalJSON implements json.Marshaler.
+func (c Condition) MarshalJSON() ([]byte, error) { return json.Marshal(c.function()) }
+
+// MarshalYAML implements yaml.Marshaler.
+func (c Condition) MarshalYAML() (interface{}, error) { return c.function(), nil }
+
+func nonNil(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+
+	return values
+}
@@ -0,0 +1,210 @@
+// Package template models CloudFormation templates as Go values, which serialize to deterministic YAML or JSON.
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/moia-oss/go-deploy-cfn/lint"
+)
+
+// FormatVersion is the only valid AWSTemplateFormatVersion.
+const FormatVersion = "2010-09-09"
+
+var logicalIDRegexp = regexp.MustCompile(`^[A-Za-z0-9]{1,255}$`)
+
+// Template is a CloudFormation template. Property values can be plain Go values or intrinsic functions
+// like Ref, GetAtt or Sub.
+type Template struct {
+	AWSTemplateFormatVersion string                            `json:"AWSTemplateFormatVersion,omitempty" yaml:"AWSTemplateFormatVersion,omitempty"`
+	Description              string                            `json:"Description,omitempty" yaml:"Description,omitempty"`
+	Metadata                 map[string]interface{}            `json:"Metadata,omitempty" yaml:"Metadata,omitempty"`
+	Parameters               map[string]*Parameter             `json:"Parameters,omitempty" yaml:"Parameters,omitempty"`
+	Mappings                 map[string]map[string]interface{} `json:"Mappings,omitempty" yaml:"Mappings,omitempty"`
+	Conditions               map[string]interface{}            `json:"Conditions,omitempty" yaml:"Conditions,omitempty"`
+	Resources                map[string]*Resource              `json:"Resources" yaml:"Resources"`
+	Outputs                  map[string]*Output                `json:"Outputs,omitempty" yaml:"Outputs,omitempty"`
+}
+
+// Parameter is an input of the template.
+type Parameter struct {
+	Type                  string      `json:"Type" yaml:"Type"`
+	Description           string      `json:"Description,omitempty" yaml:"Description,omitempty"`
+	Default               interface{} `json:"Default,omitempty" yaml:"Default,omitempty"`
+	AllowedValues         []string    `json:"AllowedValues,omitempty" yaml:"AllowedValues,omitempty"`
+	AllowedPattern        string      `json:"AllowedPattern,omitempty" yaml:"AllowedPattern,omitempty"`
+	ConstraintDescription string      `json:"ConstraintDescription,omitempty" yaml:"ConstraintDescription,omitempty"`
+	MinLength             *int        `json:"MinLength,omitempty" yaml:"MinLength,omitempty"`
+	MaxLength             *int        `json:"MaxLength,omitempty" yaml:"MaxLength,omitempty"`
+	MinValue              *float64    `json:"MinValue,omitempty" yaml:"MinValue,omitempty"`
+	MaxValue              *float64    `json:"MaxValue,omitempty" yaml:"MaxValue,omitempty"`
+	NoEcho                bool        `json:"NoEcho,omitempty" yaml:"NoEcho,omitempty"`
+}
+
+// Resource is a single resource of the template.
+type Resource struct {
+	Type                string                 `json:"Type" yaml:"Type"`
+	Condition           string                 `json:"Condition,omitempty" yaml:"Condition,omitempty"`
+	DependsOn           []string               `json:"DependsOn,omitempty" yaml:"DependsOn,omitempty"`
+	DeletionPolicy      string                 `json:"DeletionPolicy,omitempty" yaml:"DeletionPolicy,omitempty"`
+	UpdateReplacePolicy string                 `json:"UpdateReplacePolicy,omitempty" yaml:"UpdateReplacePolicy,omitempty"`
+	Metadata            map[string]interface{} `json:"Metadata,omitempty" yaml:"Metadata,omitempty"`
+	Properties          map[string]interface{} `json:"Properties,omitempty" yaml:"Properties,omitempty"`
+}
+
+// Output is a value returned by the stack.
+type Output struct {
+	Description string      `json:"Description,omitempty" yaml:"Description,omitempty"`
+	Condition   string      `json:"Condition,omitempty" yaml:"Condition,omitempty"`
+	Value       interface{} `json:"Value" yaml:"Value"`
+	Export      *Export     `json:"Export,omitempty" yaml:"Export,omitempty"`
+}
+
+// Export makes an Output importable by other stacks.
+type Export struct {
+	Name interface{} `json:"Name" yaml:"Name"`
+}
+
+// New returns an empty template with the format version set.
+func New(description string) *Template {
+	return &Template{
+		AWSTemplateFormatVersion: FormatVersion,
+		Description:              description,
+		Resources:                map[string]*Resource{},
+	}
+}
+
+// AddParameter adds a parameter and returns a Ref to it.
+func (t *Template) AddParameter(logicalID string, p *Parameter) (Ref, error) {
+	if err := checkLogicalID("parameter", logicalID, t.Parameters[logicalID] != nil); err != nil {
+		return Ref{}, err
+	}
+
+	if t.Parameters == nil {
+		t.Parameters = map[string]*Parameter{}
+	}
+
+	t.Parameters[logicalID] = p
+
+	return Ref{Name: logicalID}, nil
+}
+
+// AddCondition adds a condition, e.g. an Equals function.
+func (t *Template) AddCondition(logicalID string, condition interface{}) error {
+	if err := checkLogicalID("condition", logicalID, t.Conditions[logicalID] != nil); err != nil {
+		return err
+	}
+
+	if t.Conditions == nil {
+		t.Conditions = map[string]interface{}{}
+	}
+
+	t.Conditions[logicalID] = condition
+
+	return nil
+}
+
+// AddResource adds a resource and returns a Ref to it.
+func (t *Template) AddResource(logicalID string, r *Resource) (Ref, error) {
+	if err := checkLogicalID("resource", logicalID, t.Resources[logicalID] != nil); err != nil {
+		return Ref{}, err
+	}
+
+	if t.Resources == nil {
+		t.Resources = map[string]*Resource{}
+	}
+
+	t.Resources[logicalID] = r
+
+	return Ref{Name: logicalID}, nil
+}
+
+// AddOutput adds an output.
+func (t *Template) AddOutput(logicalID string, o *Output) error {
+	if err := checkLogicalID("output", logicalID, t.Outputs[logicalID] != nil); err != nil {
+		return err
+	}
+
+	if t.Outputs == nil {
+		t.Outputs = map[string]*Output{}
+	}
+
+	t.Outputs[logicalID] = o
+
+	return nil
+}
+
+func checkLogicalID(kind, logicalID string, exists bool) error {
+	if !logicalIDRegexp.MatchString(logicalID) {
+		return fmt.Errorf("invalid logical ID %q for %s: must be alphanumeric and at most 255 characters long", logicalID, kind)
+	}
+
+	if exists {
+		return fmt.Errorf("%s %q is already defined", kind, logicalID)
+	}
+
+	return nil
+}
+
+// YAML serializes the template. Keys are sorted, so equal templates result in equal YAML.
+func (t *Template) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(t); err != nil {
+		return nil, fmt.Errorf("error encoding template as YAML: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding template as YAML: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// JSON serializes the template as indented JSON. Keys are sorted, so equal templates result in equal JSON.
+func (t *Template) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding template as JSON: %w", err)
+	}
+
+	return b, nil
+}
+
+// TemplateBody returns the YAML template, so a *Template can be passed to
+// Cloudformation.CloudFormationDeployTemplate.
+func (t *Template) TemplateBody() (string, error) {
+	b, err := t.YAML()
+
+	return string(b), err
+}
+
+// Lint runs the offline linter on the serialized template.
+func (t *Template) Lint() ([]lint.Finding, error) {
+	b, err := t.YAML()
+	if err != nil {
+		return nil, err
+	}
+
+	return lint.Lint(b)
+}
+
+// LogicalIDs returns the sorted logical IDs of all resources.
+func (t *Template) LogicalIDs() []string {
+	ids := make([]string, 0, len(t.Resources))
+	for id := range t.Resources {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
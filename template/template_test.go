@@ -0,0 +1,134 @@
+package template
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testTemplate(t *testing.T) *Template {
+	t.Helper()
+
+	tmpl := New("test template")
+
+	env, err := tmpl.AddParameter("Env", &Parameter{Type: "String", AllowedValues: []string{"dev", "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tmpl.AddCondition("IsProd", Equals{A: env, B: "prod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	topic, err := tmpl.AddResource("Topic", &Resource{
+		Type: "AWS::SNS::Topic",
+		Properties: map[string]interface{}{
+			"TopicName": Sub{String: "${AWS::StackName}-${Env}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tmpl.AddResource("Alarm", &Resource{
+		Type:      "AWS::CloudWatch::Alarm",
+		Condition: "IsProd",
+		Properties: map[string]interface{}{
+			"AlarmActions": []interface{}{topic},
+			"AlarmName":    Join{Delimiter: "-", Values: []interface{}{StackName, GetAtt{Resource: "Topic", Attribute: "TopicName"}}},
+			"Threshold":    If{Condition: "IsProd", True: 1, False: NoValue},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tmpl.AddOutput("TopicArn", &Output{
+		Value:  topic,
+		Export: &Export{Name: Sub{String: "${AWS::StackName}-TopicArn"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpl
+}
+
+func TestTemplate_YAML(t *testing.T) {
+	first, err := testTemplate(t).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		again, err := testTemplate(t).YAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(again) != string(first) {
+			t.Fatalf("YAML() is not deterministic:\n%s\n---\n%s", first, again)
+		}
+	}
+
+	var fromYAML, fromJSON interface{}
+	if err = yaml.Unmarshal(first, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := testTemplate(t).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip the YAML through JSON to get the same number and map types
+	normalizedYAML, _ := json.Marshal(fromYAML)
+	_ = json.Unmarshal(normalizedYAML, &fromYAML)
+
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("YAML and JSON differ:\n%s\n---\n%s", first, j)
+	}
+
+	alarmName := fromJSON.(map[string]interface{})["Resources"].(map[string]interface{})["Alarm"].(map[string]interface{})["Properties"].(map[string]interface{})["AlarmName"]
+
+	want := map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{
+		map[string]interface{}{"Ref": "AWS::StackName"},
+		map[string]interface{}{"Fn::GetAtt": []interface{}{"Topic", "TopicName"}},
+	}}}
+	if !reflect.DeepEqual(alarmName, want) {
+		t.Errorf("AlarmName = %v, want %v", alarmName, want)
+	}
+}
+
+func TestTemplate_Lint(t *testing.T) {
+	findings, err := testTemplate(t).Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(findings) != 0 {
+		t.Errorf("Lint() = %v, want no findings", findings)
+	}
+}
+
+func TestTemplate_AddResource(t *testing.T) {
+	tmpl := New("")
+
+	if _, err := tmpl.AddResource("My-Topic", &Resource{Type: "AWS::SNS::Topic"}); err == nil {
+		t.Error("AddResource() expected an error for an invalid logical ID")
+	}
+
+	if _, err := tmpl.AddResource("Topic", &Resource{Type: "AWS::SNS::Topic"}); err != nil {
+		t.Fatalf("AddResource() unexpected error = %v", err)
+	}
+
+	if _, err := tmpl.AddResource("Topic", &Resource{Type: "AWS::SNS::Topic"}); err == nil {
+		t.Error("AddResource() expected an error for a duplicate logical ID")
+	}
+}
@@ -0,0 +1,119 @@
+package godeploycfn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// ErrTerminationProtected is returned by CloudFormationDelete for protected stacks unless forced.
+var ErrTerminationProtected = errors.New("stack has termination protection enabled")
+
+// describeStack returns the stack, or nil if it does not exist.
+func (c *Cloudformation) describeStack() (*cloudformation.Stack, error) {
+	//nolint
+	dso, err := c.CFClient.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(c.StackName),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("unexpected error while describing stack: %w", err)
+	}
+
+	if len(dso.Stacks) != 1 {
+		return nil, fmt.Errorf("unexpected (!=1) number of stacks in result: %v", len(dso.Stacks))
+	}
+
+	return dso.Stacks[0], nil
+}
+
+func (c *Cloudformation) updateTerminationProtection(enable bool) error {
+	_, err := c.CFClient.UpdateTerminationProtection(&cloudformation.UpdateTerminationProtectionInput{
+		EnableTerminationProtection: aws.Bool(enable),
+		StackName:                   aws.String(c.StackName),
+	})
+	if err != nil {
+		return fmt.Errorf("error updating termination protection: %w", err)
+	}
+
+	return nil
+}
+
+// syncTerminationProtection enables or disables termination protection according to TerminationProtection.
+func (c *Cloudformation) syncTerminationProtection() error {
+	if c.TerminationProtection == nil {
+		return nil
+	}
+
+	stack, err := c.describeStack()
+	if err != nil {
+		return err
+	}
+
+	if stack == nil {
+		return fmt.Errorf("stack %s does not exist, can't update termination protection", c.StackName)
+	}
+
+	if aws.BoolValue(stack.EnableTerminationProtection) == *c.TerminationProtection {
+		return nil
+	}
+
+	c.logger().Infof("Setting termination protection to %t.", *c.TerminationProtection)
+
+	return c.updateTerminationProtection(*c.TerminationProtection)
+}
+
+// CloudFormationDelete deletes the stack and waits for the deletion to complete. Stacks with termination
+// protection are only deleted if force is set, in which case the protection is disabled first.
+func (c *Cloudformation) CloudFormationDelete(force bool) error {
+	stack, err := c.describeStack()
+	if err != nil {
+		return err
+	}
+
+	if stack == nil {
+		c.logger().Info("Stack does not exist, nothing to delete.")
+
+		return nil
+	}
+
+	if aws.BoolValue(stack.EnableTerminationProtection) {
+		if !force {
+			return fmt.Errorf("refusing to delete stack %s: %w", c.StackName, ErrTerminationProtected)
+		}
+
+		c.logger().Warn("Disabling termination protection to force the deletion.")
+
+		if err = c.updateTerminationProtection(false); err != nil {
+			return err
+		}
+	}
+
+	//nolint
+	_, err = c.CFClient.DeleteStack(&cloudformation.DeleteStackInput{
+		StackName: aws.String(c.StackName),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting stack: %w", err)
+	}
+
+	err = c.CFClient.WaitUntilStackDeleteCompleteWithContext(context.Background(),
+		&cloudformation.DescribeStacksInput{StackName: stack.StackId},
+		request.WithWaiterDelay(request.ConstantWaiterDelay(initialRetryPeriod)),
+		request.WithWaiterMaxAttempts(int(maxRetryTimeForStack/initialRetryPeriod)))
+	if err != nil {
+		return fmt.Errorf("error waiting for stack deletion: %w", err)
+	}
+
+	c.logger().Info("Stack has been deleted.")
+
+	return nil
+}
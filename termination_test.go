@@ -0,0 +1,131 @@
+package godeploycfn
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockProtectedStackClient struct {
+	cloudformationiface.CloudFormationAPI
+	exists    bool
+	protected *bool
+	updates   *int
+	deleted   *bool
+}
+
+func newMockProtectedStackClient(exists, protected bool) mockProtectedStackClient {
+	return mockProtectedStackClient{
+		exists:    exists,
+		protected: &protected,
+		updates:   new(int),
+		deleted:   new(bool),
+	}
+}
+
+func (m mockProtectedStackClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	if !m.exists {
+		return nil, fmt.Errorf("stackname %v does not exist", *input.StackName)
+	}
+
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{
+				StackId:                     aws.String("arn:stack"),
+				StackName:                   input.StackName,
+				StackStatus:                 aws.String(cloudformation.StackStatusUpdateComplete),
+				EnableTerminationProtection: aws.Bool(*m.protected),
+			},
+		},
+	}, nil
+}
+
+func (m mockProtectedStackClient) UpdateTerminationProtection(
+	input *cloudformation.UpdateTerminationProtectionInput,
+) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	*m.protected = *input.EnableTerminationProtection
+	*m.updates++
+
+	return &cloudformation.UpdateTerminationProtectionOutput{}, nil
+}
+
+func (m mockProtectedStackClient) DeleteStack(*cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+	if *m.protected {
+		return nil, errors.New("stack is protected")
+	}
+
+	*m.deleted = true
+
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (m mockProtectedStackClient) WaitUntilStackDeleteCompleteWithContext(aws.Context, *cloudformation.DescribeStacksInput,
+	...request.WaiterOption,
+) error {
+	return nil
+}
+
+func TestCloudformation_syncTerminationProtection(t *testing.T) {
+	tests := []struct {
+		name        string
+		protected   bool
+		want        *bool
+		wantUpdates int
+	}{
+		{name: "Test unset option is ignored", protected: true, want: nil, wantUpdates: 0},
+		{name: "Test protection gets enabled", protected: false, want: aws.Bool(true), wantUpdates: 1},
+		{name: "Test protection gets disabled", protected: true, want: aws.Bool(false), wantUpdates: 1},
+		{name: "Test equal protection is not updated", protected: true, want: aws.Bool(true), wantUpdates: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockProtectedStackClient(true, tt.protected)
+			c := &Cloudformation{CFClient: client, StackName: "stack", TerminationProtection: tt.want}
+
+			if err := c.syncTerminationProtection(); err != nil {
+				t.Fatalf("syncTerminationProtection() unexpected error = %v", err)
+			}
+
+			if *client.updates != tt.wantUpdates {
+				t.Errorf("UpdateTerminationProtection calls = %d, want %d", *client.updates, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestCloudformation_CloudFormationDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		protected   bool
+		force       bool
+		wantErr     error
+		wantDeleted bool
+	}{
+		{name: "Test unprotected stack is deleted", exists: true, wantDeleted: true},
+		{name: "Test protected stack is refused", exists: true, protected: true, wantErr: ErrTerminationProtected},
+		{name: "Test protected stack is deleted when forced", exists: true, protected: true, force: true, wantDeleted: true},
+		{name: "Test missing stack is ignored", exists: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockProtectedStackClient(tt.exists, tt.protected)
+			c := &Cloudformation{CFClient: client, StackName: "stack"}
+
+			if err := c.CloudFormationDelete(tt.force); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CloudFormationDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if *client.deleted != tt.wantDeleted {
+				t.Errorf("deleted = %t, want %t", *client.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package godeploycfn
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+const (
+	defaultThrottleRetries   = 10
+	defaultThrottleBaseDelay = 500 * time.Millisecond
+	defaultThrottleMaxDelay  = 20 * time.Second
+)
+
+// ThrottleStats counts the calls of a ThrottledClient.
+type ThrottleStats struct {
+	// Calls is the number of calls, without retries.
+	Calls int64
+	// Throttled is the number of throttling errors, every one of them was retried unless the call is Exhausted.
+	Throttled int64
+	// Exhausted is the number of calls which were still throttled after MaxRetries retries.
+	Exhausted int64
+}
+
+// ThrottledClient wraps a CloudFormation client with a token bucket rate limit, and retries calls failing with
+// throttling errors with jittered exponential backoff. The retries are independent of the timeouts of the
+// deployment, which only sees the final result. It's safe for concurrent use, so share a single client between
+// all stacks deployed in parallel to the same account and region.
+//
+// The calls and waiters used by this library are limited; all others are passed to the wrapped client.
+type ThrottledClient struct {
+	cloudformationiface.CloudFormationAPI
+	// MaxRetries is the number of retries of a throttled call, BaseDelay and MaxDelay bound the backoff.
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	bucket *tokenBucket
+	// sleep is replaced in tests.
+	sleep func(time.Duration)
+
+	calls, throttled, exhausted int64
+}
+
+// NewThrottledClient wraps client, allowing requestsPerSecond calls with bursts of up to burst calls. There is no
+// rate limit if requestsPerSecond isn't positive.
+func NewThrottledClient(client cloudformationiface.CloudFormationAPI, requestsPerSecond float64, burst int) *ThrottledClient {
+	t := &ThrottledClient{
+		CloudFormationAPI: client,
+		MaxRetries:        defaultThrottleRetries,
+		BaseDelay:         defaultThrottleBaseDelay,
+		MaxDelay:          defaultThrottleMaxDelay,
+		sleep:             time.Sleep,
+	}
+
+	if requestsPerSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+
+		t.bucket = &tokenBucket{rate: requestsPerSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	}
+
+	return t
+}
+
+// Stats returns the counters of the client.
+func (t *ThrottledClient) Stats() ThrottleStats {
+	return ThrottleStats{
+		Calls:     atomic.LoadInt64(&t.calls),
+		Throttled: atomic.LoadInt64(&t.throttled),
+		Exhausted: atomic.LoadInt64(&t.exhausted),
+	}
+}
+
+// call runs fn once the rate limit allows it, and retries it as long as it's throttled.
+func (t *ThrottledClient) call(fn func() error) error {
+	atomic.AddInt64(&t.calls, 1)
+
+	for attempt := 0; ; attempt++ {
+		if t.bucket != nil {
+			if wait := t.bucket.reserve(time.Now()); wait > 0 {
+				t.sleep(wait)
+			}
+		}
+
+		err := fn()
+		if !request.IsErrorThrottle(err) {
+			return err
+		}
+
+		atomic.AddInt64(&t.throttled, 1)
+
+		if attempt >= t.MaxRetries {
+			atomic.AddInt64(&t.exhausted, 1)
+
+			return err
+		}
+
+		t.sleep(t.backoff(attempt))
+	}
+}
+
+// backoff returns a random delay between zero and the exponential backoff of the attempt ("full jitter").
+func (t *ThrottledClient) backoff(attempt int) time.Duration {
+	limit := t.MaxDelay
+	if attempt < 32 && t.BaseDelay<<attempt < limit {
+		limit = t.BaseDelay << attempt
+	}
+
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit))) //nolint:gosec
+}
+
+// throttled calls fn with in through the rate limit and retries of t.
+func throttled[I, O any](t *ThrottledClient, fn func(I) (O, error), in I) (O, error) {
+	var out O
+
+	err := t.call(func() error {
+		var err error
+
+		out, err = fn(in)
+
+		return err
+	})
+
+	return out, err
+}
+
+// tokenBucket is a token bucket rate limiter. Tokens may go negative, so waiting callers are served in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait until it's available.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+
+		b.last = now
+	}
+
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// CreateChangeSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) CreateChangeSet(in *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.CreateChangeSet, in)
+}
+
+// CreateStackInstances calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) CreateStackInstances(in *cloudformation.CreateStackInstancesInput) (
+	*cloudformation.CreateStackInstancesOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.CreateStackInstances, in)
+}
+
+// CreateStackSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) CreateStackSet(in *cloudformation.CreateStackSetInput) (*cloudformation.CreateStackSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.CreateStackSet, in)
+}
+
+// DeleteChangeSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DeleteChangeSet(in *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DeleteChangeSet, in)
+}
+
+// DeleteStack calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DeleteStack(in *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DeleteStack, in)
+}
+
+// DeleteStackInstances calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DeleteStackInstances(in *cloudformation.DeleteStackInstancesInput) (
+	*cloudformation.DeleteStackInstancesOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.DeleteStackInstances, in)
+}
+
+// DescribeChangeSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeChangeSet(in *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DescribeChangeSet, in)
+}
+
+// DescribeStackDriftDetectionStatus calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStackDriftDetectionStatus(in *cloudformation.DescribeStackDriftDetectionStatusInput) (
+	*cloudformation.DescribeStackDriftDetectionStatusOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.DescribeStackDriftDetectionStatus, in)
+}
+
+// DescribeStackEvents calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStackEvents(in *cloudformation.DescribeStackEventsInput) (
+	*cloudformation.DescribeStackEventsOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.DescribeStackEvents, in)
+}
+
+// DescribeStackResourceDrifts calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStackResourceDrifts(in *cloudformation.DescribeStackResourceDriftsInput) (
+	*cloudformation.DescribeStackResourceDriftsOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.DescribeStackResourceDrifts, in)
+}
+
+// DescribeStackSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStackSet(in *cloudformation.DescribeStackSetInput) (*cloudformation.DescribeStackSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DescribeStackSet, in)
+}
+
+// DescribeStackSetOperation calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStackSetOperation(in *cloudformation.DescribeStackSetOperationInput) (
+	*cloudformation.DescribeStackSetOperationOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.DescribeStackSetOperation, in)
+}
+
+// DescribeStacks calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DescribeStacks(in *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DescribeStacks, in)
+}
+
+// DetectStackDrift calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) DetectStackDrift(in *cloudformation.DetectStackDriftInput) (*cloudformation.DetectStackDriftOutput, error) {
+	return throttled(t, t.CloudFormationAPI.DetectStackDrift, in)
+}
+
+// EstimateTemplateCost calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) EstimateTemplateCost(in *cloudformation.EstimateTemplateCostInput) (
+	*cloudformation.EstimateTemplateCostOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.EstimateTemplateCost, in)
+}
+
+// ExecuteChangeSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ExecuteChangeSet(in *cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ExecuteChangeSet, in)
+}
+
+// GetStackPolicy calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) GetStackPolicy(in *cloudformation.GetStackPolicyInput) (*cloudformation.GetStackPolicyOutput, error) {
+	return throttled(t, t.CloudFormationAPI.GetStackPolicy, in)
+}
+
+// GetTemplate calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) GetTemplate(in *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	return throttled(t, t.CloudFormationAPI.GetTemplate, in)
+}
+
+// ListChangeSets calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ListChangeSets(in *cloudformation.ListChangeSetsInput) (*cloudformation.ListChangeSetsOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ListChangeSets, in)
+}
+
+// ListStackInstances calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ListStackInstances(in *cloudformation.ListStackInstancesInput) (*cloudformation.ListStackInstancesOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ListStackInstances, in)
+}
+
+// ListStackResources calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ListStackResources(in *cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ListStackResources, in)
+}
+
+// ListStackSetOperationResults calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ListStackSetOperationResults(in *cloudformation.ListStackSetOperationResultsInput) (
+	*cloudformation.ListStackSetOperationResultsOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.ListStackSetOperationResults, in)
+}
+
+// ListStacks calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ListStacks(in *cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ListStacks, in)
+}
+
+// SetStackPolicy calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) SetStackPolicy(in *cloudformation.SetStackPolicyInput) (*cloudformation.SetStackPolicyOutput, error) {
+	return throttled(t, t.CloudFormationAPI.SetStackPolicy, in)
+}
+
+// UpdateStackSet calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) UpdateStackSet(in *cloudformation.UpdateStackSetInput) (*cloudformation.UpdateStackSetOutput, error) {
+	return throttled(t, t.CloudFormationAPI.UpdateStackSet, in)
+}
+
+// UpdateTerminationProtection calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) UpdateTerminationProtection(in *cloudformation.UpdateTerminationProtectionInput) (
+	*cloudformation.UpdateTerminationProtectionOutput, error,
+) {
+	return throttled(t, t.CloudFormationAPI.UpdateTerminationProtection, in)
+}
+
+// ValidateTemplate calls the wrapped client with rate limiting and retries.
+func (t *ThrottledClient) ValidateTemplate(in *cloudformation.ValidateTemplateInput) (*cloudformation.ValidateTemplateOutput, error) {
+	return throttled(t, t.CloudFormationAPI.ValidateTemplate, in)
+}
+
+// WaitUntilChangeSetCreateCompleteWithContext passes the call to the wrapped client, limiting and retrying every
+// request of the waiter.
+func (t *ThrottledClient) WaitUntilChangeSetCreateCompleteWithContext(ctx context.Context,
+	in *cloudformation.DescribeChangeSetInput, opts ...request.WaiterOption,
+) error {
+	return t.CloudFormationAPI.WaitUntilChangeSetCreateCompleteWithContext(ctx, in, append(opts, t.waiterOption())...)
+}
+
+// WaitUntilStackDeleteCompleteWithContext passes the call to the wrapped client, limiting and retrying every
+// request of the waiter.
+func (t *ThrottledClient) WaitUntilStackDeleteCompleteWithContext(ctx context.Context,
+	in *cloudformation.DescribeStacksInput, opts ...request.WaiterOption,
+) error {
+	return t.CloudFormationAPI.WaitUntilStackDeleteCompleteWithContext(ctx, in, append(opts, t.waiterOption())...)
+}
+
+// waiterOption applies the rate limit to every request of a waiter. A throttled request is retried on its own,
+// so the waiter only sees its final result.
+func (t *ThrottledClient) waiterOption() request.WaiterOption {
+	return request.WithWaiterRequestOptions(func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(*request.Request) {
+			atomic.AddInt64(&t.calls, 1)
+		})
+		r.Handlers.Send.PushFront(func(*request.Request) {
+			if t.bucket != nil {
+				if wait := t.bucket.reserve(time.Now()); wait > 0 {
+					t.sleep(wait)
+				}
+			}
+		})
+		r.Handlers.Retry.PushBack(func(r *request.Request) {
+			if request.IsErrorThrottle(r.Error) {
+				atomic.AddInt64(&t.throttled, 1)
+			}
+		})
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if request.IsErrorThrottle(r.Error) {
+				atomic.AddInt64(&t.exhausted, 1)
+			}
+		})
+
+		r.Retryer = throttleRetryer{Retryer: r.Retryer, t: t}
+	})
+}
+
+// throttleRetryer retries throttled requests with the retries and backoff of a ThrottledClient, all other errors
+// are left to the wrapped Retryer.
+type throttleRetryer struct {
+	request.Retryer
+	t *ThrottledClient
+}
+
+// MaxRetries returns the larger of both limits, ShouldRetry applies the limit of the error.
+func (r throttleRetryer) MaxRetries() int {
+	if r.t.MaxRetries > r.Retryer.MaxRetries() {
+		return r.t.MaxRetries
+	}
+
+	return r.Retryer.MaxRetries()
+}
+
+// ShouldRetry returns true if the request is throttled and has retries left, or the wrapped Retryer retries it.
+func (r throttleRetryer) ShouldRetry(req *request.Request) bool {
+	if request.IsErrorThrottle(req.Error) {
+		return req.RetryCount < r.t.MaxRetries
+	}
+
+	return req.RetryCount < r.Retryer.MaxRetries() && r.Retryer.ShouldRetry(req)
+}
+
+// RetryRules returns the backoff of the ThrottledClient for throttled requests.
+func (r throttleRetryer) RetryRules(req *request.Request) time.Duration {
+	if request.IsErrorThrottle(req.Error) {
+		return r.t.backoff(req.RetryCount)
+	}
+
+	return r.Retryer.RetryRules(req)
+}
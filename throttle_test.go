@@ -0,0 +1,213 @@
+package godeploycfn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockThrottlingClient struct {
+	cloudformationiface.CloudFormationAPI
+	errs  []error
+	calls *int
+}
+
+func (m mockThrottlingClient) DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	*m.calls++
+	if *m.calls <= len(m.errs) {
+		return nil, m.errs[*m.calls-1]
+	}
+
+	return &cloudformation.DescribeStacksOutput{}, nil
+}
+
+// WaitUntilStackDeleteCompleteWithContext runs a waiter like the SDK does, every poll sends the next error of errs.
+func (m mockThrottlingClient) WaitUntilStackDeleteCompleteWithContext(ctx context.Context, in *cloudformation.DescribeStacksInput,
+	opts ...request.WaiterOption,
+) error {
+	w := request.Waiter{
+		Name:        "WaitUntilStackDeleteComplete",
+		MaxAttempts: 5,
+		Delay:       request.ConstantWaiterDelay(0),
+		Acceptors: []request.WaiterAcceptor{
+			{State: request.SuccessWaiterState, Matcher: request.ErrorWaiterMatch, Expected: "ValidationError"},
+		},
+		NewRequest: func(reqOpts []request.Option) (*request.Request, error) {
+			handlers := request.Handlers{}
+			handlers.Send.PushBack(func(r *request.Request) {
+				*m.calls++
+				if *m.calls <= len(m.errs) {
+					r.Error = m.errs[*m.calls-1]
+				}
+			})
+			handlers.AfterRetry.PushBackNamed(corehandlers.AfterRetryHandler)
+
+			req := request.New(aws.Config{}, metadata.ClientInfo{}, handlers, client.DefaultRetryer{},
+				&request.Operation{Name: "DescribeStacks"}, in, &cloudformation.DescribeStacksOutput{})
+			req.SetContext(ctx)
+			req.ApplyOptions(reqOpts...)
+
+			return req, nil
+		},
+	}
+	w.ApplyOptions(opts...)
+
+	return w.WaitWithContext(ctx)
+}
+
+func TestThrottledClient(t *testing.T) {
+	throttle := awserr.New("Throttling", "Rate exceeded", nil)
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   bool
+		wantCalls int
+		wantStats ThrottleStats
+	}{
+		{name: "Test throttled call is retried", errs: []error{throttle, throttle}, wantCalls: 3, wantStats: ThrottleStats{Calls: 1, Throttled: 2}},
+		{
+			name:      "Test retries are exhausted",
+			errs:      []error{throttle, throttle, throttle, throttle},
+			wantErr:   true,
+			wantCalls: 3,
+			wantStats: ThrottleStats{Calls: 1, Throttled: 3, Exhausted: 1},
+		},
+		{
+			name:      "Test other errors are not retried",
+			errs:      []error{awserr.New("ValidationError", "Stack does not exist", nil)},
+			wantErr:   true,
+			wantCalls: 1,
+			wantStats: ThrottleStats{Calls: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockThrottlingClient{errs: tt.errs, calls: new(int)}
+			client := NewThrottledClient(mock, 0, 0)
+			client.MaxRetries = 2
+
+			var slept []time.Duration
+			client.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+			_, err := client.DescribeStacks(&cloudformation.DescribeStacksInput{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DescribeStacks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if *mock.calls != tt.wantCalls || client.Stats() != tt.wantStats {
+				t.Errorf("calls = %d, stats = %+v, want %d, %+v", *mock.calls, client.Stats(), tt.wantCalls, tt.wantStats)
+			}
+
+			for i, d := range slept {
+				if d < 0 || d >= client.BaseDelay<<i {
+					t.Errorf("backoff %d = %s, want below %s", i, d, client.BaseDelay<<i)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucket_reserve(t *testing.T) {
+	start := time.Now()
+	bucket := &tokenBucket{rate: 2, burst: 2, tokens: 2, last: start}
+
+	want := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := bucket.reserve(start); got != w {
+			t.Errorf("reserve() #%d = %s, want %s", i+1, got, w)
+		}
+	}
+
+	// the two reserved tokens are paid back after one second, the bucket is full after two more
+	if got := bucket.reserve(start.Add(3 * time.Second)); got != 0 {
+		t.Errorf("reserve() after refill = %s, want 0", got)
+	}
+
+	if bucket.tokens != 1 {
+		t.Errorf("tokens after refill = %v, want 1", bucket.tokens)
+	}
+}
+
+func TestNewThrottledClient_limit(t *testing.T) {
+	mock := mockThrottlingClient{calls: new(int)}
+	client := NewThrottledClient(mock, 1, 1)
+
+	var slept time.Duration
+	client.sleep = func(d time.Duration) { slept += d }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.DescribeStacks(&cloudformation.DescribeStacksInput{}); err != nil {
+			t.Fatalf("DescribeStacks() unexpected error = %v", err)
+		}
+	}
+
+	if slept < 2*time.Second-100*time.Millisecond {
+		t.Errorf("rate limit waited %s, want about 2s", slept)
+	}
+
+	if client.Stats().Calls != 3 {
+		t.Errorf("Stats() = %+v", client.Stats())
+	}
+}
+
+func TestThrottledClient_WaitUntilStackDeleteCompleteWithContext(t *testing.T) {
+	throttle := awserr.New("Throttling", "Rate exceeded", nil)
+	deleted := awserr.New("ValidationError", "Stack does not exist", nil)
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantStats ThrottleStats
+	}{
+		{
+			name:      "Test throttled polls are retried on their own",
+			errs:      []error{throttle, throttle, nil, throttle, deleted},
+			wantCalls: 5,
+			wantStats: ThrottleStats{Calls: 2, Throttled: 3},
+		},
+		{
+			name:      "Test waiter polls again after retries are exhausted",
+			errs:      []error{throttle, throttle, throttle, deleted},
+			wantCalls: 4,
+			wantStats: ThrottleStats{Calls: 2, Throttled: 3, Exhausted: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockThrottlingClient{errs: tt.errs, calls: new(int)}
+			client := NewThrottledClient(mock, 1, 1)
+			client.MaxRetries = 2
+			client.BaseDelay = time.Millisecond
+
+			var limited int
+			client.sleep = func(time.Duration) { limited++ }
+
+			err := client.WaitUntilStackDeleteCompleteWithContext(context.Background(), &cloudformation.DescribeStacksInput{})
+			if err != nil {
+				t.Fatalf("WaitUntilStackDeleteCompleteWithContext() unexpected error = %v", err)
+			}
+
+			if *mock.calls != tt.wantCalls || client.Stats() != tt.wantStats {
+				t.Errorf("calls = %d, stats = %+v, want %d, %+v", *mock.calls, client.Stats(), tt.wantCalls, tt.wantStats)
+			}
+
+			// the bucket holds a single token, so every request but the first waits for the rate limit
+			if limited != tt.wantCalls-1 {
+				t.Errorf("rate limit waited %d times, want %d", limited, tt.wantCalls-1)
+			}
+		})
+	}
+}
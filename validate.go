@@ -0,0 +1,125 @@
+package godeploycfn
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// maxTemplateBodySize is the maximum size of a template passed as TemplateBody.
+const maxTemplateBodySize = 51200
+
+var (
+	validationLocationRegexp   = regexp.MustCompile(`line (\d+)(?:, column (\d+))?`)
+	validationReferencesRegexp = regexp.MustCompile(`\[([^\]]*)\]`)
+	validationSectionRegexp    = regexp.MustCompile(`in the (\w+) block`)
+	validationUndefinedRegexp  = regexp.MustCompile(`(?:undefined|unresolved) (?:resource|parameter|condition|mapping)s? (\w+)`)
+)
+
+// TemplateValidationError describes why a template is invalid.
+type TemplateValidationError struct {
+	// Message is the message as reported by CloudFormation.
+	Message string
+	// Section is the top level template section the problem was found in, e.g. Resources, if known.
+	Section string
+	// References are the logical IDs, types or capabilities the problem refers to, if known.
+	References []string
+	// Line and Column locate syntax errors, 0 if unknown.
+	Line   int
+	Column int
+}
+
+func (e *TemplateValidationError) Error() string {
+	var location string
+
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		location = fmt.Sprintf(" (line %d, column %d)", e.Line, e.Column)
+	case e.Line > 0:
+		location = fmt.Sprintf(" (line %d)", e.Line)
+	}
+
+	return "template validation failed" + location + ": " + e.Message
+}
+
+// newTemplateValidationError extracts whatever can be found in a CloudFormation validation message.
+func newTemplateValidationError(message string) *TemplateValidationError {
+	e := &TemplateValidationError{Message: message}
+
+	if m := validationLocationRegexp.FindStringSubmatch(message); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Column, _ = strconv.Atoi(m[2])
+	}
+
+	if m := validationSectionRegexp.FindStringSubmatch(message); m != nil {
+		e.Section = m[1]
+	}
+
+	for _, m := range validationReferencesRegexp.FindAllStringSubmatch(message, -1) {
+		for _, ref := range strings.Split(m[1], ",") {
+			if ref = strings.TrimSpace(ref); ref != "" {
+				e.References = append(e.References, ref)
+			}
+		}
+	}
+
+	if m := validationUndefinedRegexp.FindStringSubmatch(message); m != nil && len(e.References) == 0 {
+		e.References = []string{m[1]}
+	}
+
+	return e
+}
+
+// Validate validates the template with CloudFormation without creating a ChangeSet. Invalid templates
+// result in a *TemplateValidationError, as do templates requiring capabilities which wouldn't be granted.
+func (c *Cloudformation) Validate(templateBody string, namedIAM bool) (*cloudformation.ValidateTemplateOutput, error) {
+	if len(templateBody) > maxTemplateBodySize {
+		return nil, &TemplateValidationError{
+			Message: fmt.Sprintf("template body is %d bytes, the maximum is %d bytes", len(templateBody), maxTemplateBodySize),
+		}
+	}
+
+	vto, err := c.CFClient.ValidateTemplate(&cloudformation.ValidateTemplateInput{
+		TemplateBody: aws.String(templateBody),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == "ValidationError" {
+			return nil, newTemplateValidationError(aerr.Message())
+		}
+
+		return nil, fmt.Errorf("error validating template: %w", err)
+	}
+
+	var missing []string
+
+	for _, capability := range aws.StringValueSlice(vto.Capabilities) {
+		switch capability {
+		case cloudformation.CapabilityCapabilityIam, cloudformation.CapabilityCapabilityNamedIam:
+			if namedIAM {
+				continue
+			}
+		case cloudformation.CapabilityCapabilityAutoExpand:
+			// ChangeSets expand transforms without it, it's only required to create or update stacks directly.
+			continue
+		}
+
+		missing = append(missing, capability)
+	}
+
+	if len(missing) > 0 {
+		return vto, &TemplateValidationError{
+			Message: fmt.Sprintf("template requires capabilities [%s] which are not granted: %s",
+				strings.Join(missing, ", "), aws.StringValue(vto.CapabilitiesReason)),
+			References: missing,
+		}
+	}
+
+	return vto, nil
+}
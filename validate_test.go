@@ -0,0 +1,124 @@
+package godeploycfn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+type mockValidateClient struct {
+	cloudformationiface.CloudFormationAPI
+	err          error
+	capabilities []string
+}
+
+func (m mockValidateClient) ValidateTemplate(*cloudformation.ValidateTemplateInput) (*cloudformation.ValidateTemplateOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &cloudformation.ValidateTemplateOutput{
+		Capabilities:       aws.StringSlice(m.capabilities),
+		CapabilitiesReason: aws.String("The following resource(s) require capabilities: [AWS::IAM::Role]"),
+	}, nil
+}
+
+func TestCloudformation_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   mockValidateClient
+		body     string
+		namedIAM bool
+		want     *TemplateValidationError
+		wantErr  bool
+	}{
+		{
+			name:   "Test valid template",
+			client: mockValidateClient{},
+			body:   "Resources: {}",
+		},
+		{
+			name: "Test unresolved dependency",
+			client: mockValidateClient{err: awserr.New("ValidationError",
+				"Template format error: Unresolved resource dependencies [Topic, Queue] in the Resources block of the template", nil)},
+			body: "Resources: {}",
+			want: &TemplateValidationError{Section: "Resources", References: []string{"Topic", "Queue"}},
+		},
+		{
+			name: "Test syntax error",
+			client: mockValidateClient{err: awserr.New("ValidationError",
+				"Template format error: YAML not well-formed. (line 3, column 7)", nil)},
+			body: "Resources: {}",
+			want: &TemplateValidationError{Line: 3, Column: 7},
+		},
+		{
+			name: "Test undefined GetAtt target",
+			client: mockValidateClient{err: awserr.New("ValidationError",
+				"Template error: instance of Fn::GetAtt references undefined resource Topic", nil)},
+			body: "Resources: {}",
+			want: &TemplateValidationError{References: []string{"Topic"}},
+		},
+		{
+			name:   "Test missing capabilities",
+			client: mockValidateClient{capabilities: []string{cloudformation.CapabilityCapabilityNamedIam}},
+			body:   "Resources: {}",
+			want:   &TemplateValidationError{References: []string{cloudformation.CapabilityCapabilityNamedIam}},
+		},
+		{
+			name:     "Test granted capabilities",
+			client:   mockValidateClient{capabilities: []string{cloudformation.CapabilityCapabilityIam}},
+			body:     "Resources: {}",
+			namedIAM: true,
+		},
+		{
+			name: "Test transform template",
+			client: mockValidateClient{capabilities: []string{
+				cloudformation.CapabilityCapabilityIam, cloudformation.CapabilityCapabilityAutoExpand,
+			}},
+			body:     "Transform: AWS::Serverless-2016-10-31\nResources: {}",
+			namedIAM: true,
+		},
+		{
+			name:   "Test oversized template",
+			client: mockValidateClient{},
+			body:   strings.Repeat("x", maxTemplateBodySize+1),
+			want:   &TemplateValidationError{},
+		},
+		{
+			name:    "Test other errors",
+			client:  mockValidateClient{err: errors.New("connection refused")},
+			body:    "Resources: {}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cloudformation{CFClient: tt.client, StackName: "stack"}
+
+			_, err := c.Validate(tt.body, tt.namedIAM)
+
+			var got *TemplateValidationError
+
+			isValidationErr := errors.As(err, &got)
+			if (err != nil) != (tt.want != nil || tt.wantErr) || isValidationErr != (tt.want != nil) {
+				t.Fatalf("Validate() error = %v, want validation error %v", err, tt.want)
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			if got.Section != tt.want.Section || got.Line != tt.want.Line || got.Column != tt.want.Column ||
+				!reflect.DeepEqual(got.References, tt.want.References) {
+				t.Errorf("Validate() error = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}